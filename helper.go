@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -54,6 +57,8 @@ func (c *SchemaHelper) ToStruct(out any) error {
 	return MapToStruct(c.raw, out)
 }
 
+// GetSchemaMapByPointer 根据 JSON pointer 获取对应的schema 会自动解析 $ref
+// 元组形式的 items 额外支持负数下标 如 /bar/-1 获取最后一个元素 注意这并非 RFC 6901 标准
 func (c *SchemaHelper) GetSchemaMapByPointer(schema map[string]interface{}, pointer string) (map[string]interface{}, error) {
 	if len(pointer) < 1 {
 		return nil, errors.New("pointer is empty")
@@ -103,6 +108,13 @@ func (c *SchemaHelper) GetSchemaMapByPointer(schema map[string]interface{}, poin
 					// 但是传入的序列不是下标数字
 					return nil, errors.New("invalid JSON pointer format index")
 				}
+				// 负数下标从末尾倒数 -1 即最后一个元素 这是对 RFC 6901 的扩展 标准中并不支持
+				if index < 0 {
+					index += len(itemsArray)
+					if index < 0 {
+						return nil, fmt.Errorf("invalid JSON pointer, segment index %s out of range", part)
+					}
+				}
 				// 传入的下标溢出了现有
 				if index >= len(itemsArray) {
 					return nil, fmt.Errorf("invalid JSON pointer, segment index %d out of range", index)
@@ -135,6 +147,227 @@ func (c *SchemaHelper) GetSchemaMapByPointer(schema map[string]interface{}, poin
 
 }
 
+// SetByPointer 将 pointer 指向的schema替换为 node 会直接修改 c.raw
+// pointer 的解析方式与 GetSchemaMapByPointer 相同 经过 $ref 时修改的是 $defs 中被引用的定义
+// 目标必须已经存在 根节点无法替换 请使用 SetSchema
+func (c *SchemaHelper) SetByPointer(pointer string, node map[string]any) error {
+	parent, last, err := c.parentByPointer(pointer)
+	if err != nil {
+		return err
+	}
+
+	switch parent["type"] {
+	case "object":
+		properties, ok := parent["properties"].(map[string]interface{})
+		if !ok {
+			return errors.New("invalid schema properties")
+		}
+		if _, ok := properties[last]; !ok {
+			return fmt.Errorf("schema properties not has key %s", last)
+		}
+		properties[last] = node
+	case "array":
+		if _, ok := parent["items"].(map[string]interface{}); ok {
+			parent["items"] = node
+			return nil
+		}
+		itemsArray, ok := parent["items"].([]interface{})
+		if !ok {
+			return errors.New("invalid schema items")
+		}
+		index, err := strconv.Atoi(last)
+		if err != nil {
+			return errors.New("invalid JSON pointer format index")
+		}
+		if index < 0 {
+			index += len(itemsArray)
+		}
+		if index < 0 || index >= len(itemsArray) {
+			return fmt.Errorf("invalid JSON pointer, segment index %s out of range", last)
+		}
+		itemsArray[index] = node
+	default:
+		return fmt.Errorf("unsupported schema type: %v", parent["type"])
+	}
+	return nil
+}
+
+// parentByPointer 返回 pointer 指向节点所在的父级schema(已解析 $ref) 以及最后一段
+func (c *SchemaHelper) parentByPointer(pointer string) (map[string]interface{}, string, error) {
+	pointer = strings.TrimPrefix(pointer, "#")
+	pointer = strings.TrimPrefix(pointer, "/")
+	if pointer == "" {
+		return nil, "", errors.New("pointer is empty")
+	}
+
+	parent := c.raw
+	last := pointer
+	if i := strings.LastIndex(pointer, "/"); i >= 0 {
+		var err error
+		parent, err = c.GetSchemaMapByPointer(c.raw, "/"+pointer[:i])
+		if err != nil {
+			return nil, "", err
+		}
+		last = pointer[i+1:]
+	}
+	parent, err := c.SchemaRefParse(parent)
+	if err != nil {
+		return nil, "", err
+	}
+	return parent, last, nil
+}
+
+// EffectiveSchema 返回 pointer 指向字段的完整约束 会解析 $ref 并合并 allOf 中的成员
+// 字段自身的关键词优先 其次是 $ref 指向的定义 最后是 allOf 的成员
+// required 取并集 min类约束取最大值 max类约束取最小值
+func (c *SchemaHelper) EffectiveSchema(pointer string) (map[string]any, error) {
+	var node map[string]interface{}
+	if p := strings.TrimPrefix(pointer, "#"); p == "" || p == "/" {
+		node = c.raw
+	} else {
+		parent, last, err := c.parentByPointer(pointer)
+		if err != nil {
+			return nil, err
+		}
+		node, err = rawChild(parent, last)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.effective(node, 0)
+}
+
+// rawChild 返回父级schema中未解析 $ref 的子节点
+func rawChild(parent map[string]interface{}, last string) (map[string]interface{}, error) {
+	switch parent["type"] {
+	case "object":
+		properties, ok := parent["properties"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("invalid schema properties")
+		}
+		child, ok := properties[last].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("schema properties not has key %s", last)
+		}
+		return child, nil
+	case "array":
+		if items, ok := parent["items"].(map[string]interface{}); ok {
+			return items, nil
+		}
+		itemsArray, ok := parent["items"].([]interface{})
+		if !ok {
+			return nil, errors.New("invalid schema items")
+		}
+		index, err := strconv.Atoi(last)
+		if err != nil {
+			return nil, errors.New("invalid JSON pointer format index")
+		}
+		if index < 0 {
+			index += len(itemsArray)
+		}
+		if index < 0 || index >= len(itemsArray) {
+			return nil, fmt.Errorf("invalid JSON pointer, segment index %s out of range", last)
+		}
+		child, ok := itemsArray[index].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("invalid JSON pointer, target schema in array but item not a map")
+		}
+		return child, nil
+	}
+	return nil, fmt.Errorf("unsupported schema type: %v", parent["type"])
+}
+
+// effective 递归解析 $ref 与 allOf 并合并为一个schema depth 用于防止循环引用
+func (c *SchemaHelper) effective(node map[string]interface{}, depth int) (map[string]interface{}, error) {
+	if depth > 32 {
+		return nil, errors.New("too many nested $ref or allOf")
+	}
+	res := make(map[string]interface{}, len(node))
+	for k, v := range node {
+		if k != "$ref" && k != "allOf" {
+			res[k] = v
+		}
+	}
+	var parts []map[string]interface{}
+	if ref, ok := node["$ref"].(string); ok {
+		target, err := c.ResolveRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, target)
+	}
+	if allOf, ok := node["allOf"].([]interface{}); ok {
+		for _, member := range allOf {
+			if m, ok := member.(map[string]interface{}); ok {
+				parts = append(parts, m)
+			}
+		}
+	}
+	for _, part := range parts {
+		flat, err := c.effective(part, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		mergeConstraints(res, flat)
+	}
+	return res, nil
+}
+
+// mergeConstraints 将 src 中的约束合并到 dst 已存在的关键词保持不变
+func mergeConstraints(dst, src map[string]interface{}) {
+	for k, v := range src {
+		existing, ok := dst[k]
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		switch k {
+		case "required":
+			a, _ := existing.([]interface{})
+			b, _ := v.([]interface{})
+			merged := append([]interface{}(nil), a...)
+			for _, item := range b {
+				found := false
+				for _, e := range merged {
+					if e == item {
+						found = true
+						break
+					}
+				}
+				if !found {
+					merged = append(merged, item)
+				}
+			}
+			dst[k] = merged
+		case "properties":
+			a, _ := existing.(map[string]interface{})
+			b, _ := v.(map[string]interface{})
+			merged := make(map[string]interface{}, len(a)+len(b))
+			for name, p := range b {
+				merged[name] = p
+			}
+			for name, p := range a {
+				merged[name] = p
+			}
+			dst[k] = merged
+		case "minLength", "minimum", "minItems", "minProperties":
+			if a, b, ok := bothNumbers(existing, v); ok && b > a {
+				dst[k] = v
+			}
+		case "maxLength", "maximum", "maxItems", "maxProperties":
+			if a, b, ok := bothNumbers(existing, v); ok && b < a {
+				dst[k] = v
+			}
+		}
+	}
+}
+
+func bothNumbers(a, b interface{}) (float64, float64, bool) {
+	x, ok1 := a.(float64)
+	y, ok2 := b.(float64)
+	return x, y, ok1 && ok2
+}
+
 func (c *SchemaHelper) SchemaRefParse(schema map[string]interface{}) (map[string]interface{}, error) {
 
 	// 处理 $ref 引用
@@ -232,6 +465,198 @@ func (c *SchemaHelper) GenAccessKeys() []string {
 	return c.accessKeys
 }
 
+// Subschema 获取pointer对应的schema 并作为独立文档返回 只会携带其直接或间接引用到的 $defs
+// pointer 格式与 GetSchemaMapByPointer 一致
+func (c *SchemaHelper) Subschema(pointer string) (map[string]any, error) {
+	node, err := c.GetSchemaMapByPointer(c.raw, pointer)
+	if err != nil {
+		return nil, err
+	}
+	result, err := StructToMap(node)
+	if err != nil {
+		return nil, err
+	}
+
+	rootDefs, _ := c.raw["$defs"].(map[string]interface{})
+	defs := make(map[string]interface{})
+	var collect func(v interface{}) error
+	collect = func(v interface{}) error {
+		switch item := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := item["$ref"].(string); ok {
+				if !strings.HasPrefix(ref, "#/$defs/") {
+					return fmt.Errorf("不支持的引用 %s", ref)
+				}
+				name := strings.TrimPrefix(ref, "#/$defs/")
+				if _, ok := defs[name]; !ok {
+					def, ok := rootDefs[name]
+					if !ok {
+						return fmt.Errorf("未找到对应schema %s", ref)
+					}
+					defs[name] = def
+					if err := collect(def); err != nil {
+						return err
+					}
+				}
+			}
+			for _, child := range item {
+				if err := collect(child); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			for _, child := range item {
+				if err := collect(child); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := collect(result); err != nil {
+		return nil, err
+	}
+
+	if len(defs) > 0 {
+		copied, err := StructToMap(defs)
+		if err != nil {
+			return nil, err
+		}
+		result["$defs"] = copied
+	}
+	if version, ok := c.raw["$schema"]; ok {
+		result["$schema"] = version
+	}
+	return result, nil
+}
+
+// 遍历收集必填字段的accessKey
+func (c *SchemaHelper) traverseRequired(currentSchema map[string]interface{}, currentPath string, paths *[]string) error {
+	schema, err := c.SchemaRefParse(currentSchema)
+	if err != nil {
+		return err
+	}
+	joinPath := func(name string) string {
+		if currentPath == "" {
+			return name
+		}
+		return currentPath + "." + name
+	}
+
+	typ, _ := schema["type"].(string)
+	if typ == "object" {
+		if widget, ok := schema["widget"].(string); ok && widget == "RawJsonTree" {
+			return nil
+		}
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if n, ok := name.(string); ok {
+					*paths = append(*paths, joinPath(n))
+				}
+			}
+		}
+		if properties, ok := schema["properties"].(map[string]interface{}); ok {
+			names := make([]string, 0, len(properties))
+			for propertyName := range properties {
+				names = append(names, propertyName)
+			}
+			sort.Strings(names)
+			for _, propertyName := range names {
+				if propertySchema, ok := properties[propertyName].(map[string]interface{}); ok {
+					if err := c.traverseRequired(propertySchema, joinPath(propertyName), paths); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	} else if typ == "array" {
+		if items, ok := schema["items"].([]interface{}); ok {
+			for index, item := range items {
+				if itemSchema, ok := item.(map[string]interface{}); ok {
+					if err := c.traverseRequired(itemSchema, joinPath(strconv.Itoa(index)), paths); err != nil {
+						return err
+					}
+				}
+			}
+		} else if itemsSchema, ok := schema["items"].(map[string]interface{}); ok {
+			return c.traverseRequired(itemsSchema, joinPath("*"), paths)
+		}
+	}
+	return nil
+}
+
+// RequiredPaths 递归获取所有必填字段的accessKey列表 会解析$ref 数组元素中的必填字段使用 * 表示 例如 items.*.field
+// $ref 无法解析时返回错误
+func (c *SchemaHelper) RequiredPaths() ([]string, error) {
+	paths := make([]string, 0)
+	if err := c.traverseRequired(c.raw, "", &paths); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// DataChange 描述两个数据文档在某个accessKey上的差异
+type DataChange struct {
+	Path string
+	Kind string // DataAdded DataRemoved 或 DataChanged
+	Old  any
+	New  any
+}
+
+const (
+	DataAdded   = "added"
+	DataRemoved = "removed"
+	DataChanged = "changed"
+)
+
+// DiffData 对比两个符合schema的数据文档 按 GenAccessKeys 生成的accessKey逐个比较 返回按路径排序的差异
+// 数据会先经过json序列化 因此可以传入结构体 数组元素的字段 items.*.field 会作为一个整体比较
+func (c *SchemaHelper) DiffData(oldData, newData any) []DataChange {
+	oldData, newData = jsonRoundTrip(oldData), jsonRoundTrip(newData)
+	keys := append([]string(nil), c.GenAccessKeys()...)
+	sort.Strings(keys)
+
+	changes := make([]DataChange, 0)
+	for _, key := range keys {
+		oldValue, newValue := FindDataByAccessKey(oldData, key), FindDataByAccessKey(newData, key)
+		change := DataChange{Path: key, Old: oldValue, New: newValue}
+		switch {
+		case isAbsentData(oldValue) && isAbsentData(newValue):
+			continue
+		case isAbsentData(oldValue):
+			change.Kind = DataAdded
+		case isAbsentData(newValue):
+			change.Kind = DataRemoved
+		case !reflect.DeepEqual(oldValue, newValue):
+			change.Kind = DataChanged
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// jsonRoundTrip 将数据转换为json解码后的通用结构 失败时原样返回
+func jsonRoundTrip(in any) any {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return in
+	}
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return in
+	}
+	return out
+}
+
+// isAbsentData FindDataByAccessKey 找不到数据时返回 nil 通配符路径没有收集到任何元素时返回 nil 切片
+// 数据中真实存在的空数组不算缺失 按值比较
+func isAbsentData(v any) bool {
+	arr, ok := v.([]any)
+	return v == nil || ok && arr == nil
+}
+
 func NewSchemaHelper(input any) *SchemaHelper {
 	var t = new(SchemaHelper)
 	t.SetSchema(input)
@@ -242,6 +667,7 @@ func NewSchemaHelper(input any) *SchemaHelper {
 
 // GetSchemaMapByPointer 传入一个被序列化之后的 json schema , 和对应需要获取的pointer , 返回 获取到的schema 或者 error
 // pointer 格式为 /字段1/字段2 或者 #/字段1/字段2
+// 元组形式的items可使用负数下标 如 /字段/-1 (非 RFC 6901 标准的扩展)
 func GetSchemaMapByPointer(schema map[string]interface{}, pointer string) (map[string]interface{}, error) {
 	var t = NewSchemaHelper(schema)
 	return t.GetSchemaMapByPointer(t.raw, pointer)
@@ -363,6 +789,76 @@ func FindDataByAccessKey(data interface{}, accessKey string) interface{} {
 	return currentData
 }
 
+// GetString 通过 FindDataByAccessKey 获取字符串 不存在或类型不匹配时 ok 为 false
+func GetString(data any, accessKey string) (string, bool) {
+	return findAs[string](data, accessKey)
+}
+
+// GetSlice 通过 FindDataByAccessKey 获取数组 不存在或类型不匹配时 ok 为 false
+func GetSlice(data any, accessKey string) ([]any, bool) {
+	return findAs[[]any](data, accessKey)
+}
+
+// GetFloat 通过 FindDataByAccessKey 获取数字 支持各类整数 浮点数与 json.Number
+func GetFloat(data any, accessKey string) (float64, bool) {
+	switch v := FindDataByAccessKey(data, accessKey).(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	if i, ok := GetInt(data, accessKey); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+// GetInt 通过 FindDataByAccessKey 获取整数 json 解码得到的 float64 必须没有小数部分
+func GetInt(data any, accessKey string) (int64, bool) {
+	switch v := FindDataByAccessKey(data, accessKey).(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint:
+		if uint64(v) <= math.MaxInt64 {
+			return int64(v), true
+		}
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v), true
+		}
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<63 {
+			return int64(v), true
+		}
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func findAs[T any](data any, accessKey string) (T, bool) {
+	v, ok := FindDataByAccessKey(data, accessKey).(T)
+	return v, ok
+}
+
 // StructToMap 通过json序列化实现struct到map
 func StructToMap(in any) (map[string]any, error) {
 	b, err := json.Marshal(in)
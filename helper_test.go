@@ -61,6 +61,13 @@ func TestGetSchemaMapByPointer(t *testing.T) {
 			"baz": map[string]interface{}{"type": "string"},
 		}, "required": []interface{}{"baz"}}, false},
 		{"/bar/1/baz", map[string]interface{}{"type": "string"}, false},
+		// 负数下标从末尾倒数 非 RFC 6901 标准
+		{"/bar/-1", map[string]interface{}{"type": "object", "properties": map[string]interface{}{
+			"baz": map[string]interface{}{"type": "string"},
+		}, "required": []interface{}{"baz"}}, false},
+		{"/bar/-2", map[string]interface{}{"type": "number"}, false},
+		{"/bar/-1/baz", map[string]interface{}{"type": "string"}, false},
+		{"/bar/-3", nil, true},
 		// 暂时不支持 - ~ 这种操作符
 		{"/bar/-", nil, true},
 		{"/bar/-/baz", nil, true},
@@ -257,3 +264,234 @@ func TestFindDataByAccessKey(t *testing.T) {
 		t.Errorf("Expected %v but got %v", expected, result)
 	}
 }
+
+func TestSchemaHelper_RequiredPaths(t *testing.T) {
+	refSchema := `{"$defs":{"ModelIndex":{"additionalProperties":false,"properties":{"field_name":{"items":{"type":"string"},"type":"array"},"type":{"type":"string"}},"type":"object"},"RawSchema":{"type":"object","widget":"RawJsonTree"}},"$id":"https://resok.cn/s/schemas/model","$schema":"https://json-schema.org/draft/2020-12/schema","additionalProperties":false,"properties":{"backend":{"default":"mongodb","enum":["mongodb"],"type":"string"},"desc":{"type":"string"},"fieldsDefine":{"$ref":"#/$defs/RawSchema"},"group":{"type":"string"},"indexes":{"items":{"$ref":"#/$defs/ModelIndex"},"type":"array"},"title":{"type":"string"},"user_id":{"type":"string"}},"required":["fieldsDefine","title"],"title":"模型","type":"object"}`
+	var refSchemaJSON map[string]interface{}
+	if err := json.Unmarshal([]byte(refSchema), &refSchemaJSON); err != nil {
+		t.Fatalf("failed to unmarshal schema: %v", err)
+	}
+	helper := NewSchemaHelper(refSchemaJSON)
+	paths, err := helper.RequiredPaths()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"fieldsDefine", "title"}, paths)
+
+	// 数组元素中的必填字段
+	refSchemaJSON["$defs"].(map[string]interface{})["ModelIndex"].(map[string]interface{})["required"] = []interface{}{"type"}
+	helper = NewSchemaHelper(refSchemaJSON)
+	paths, err = helper.RequiredPaths()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"fieldsDefine", "title", "indexes.*.type"}, paths)
+
+	// 无法解析的 $ref 返回错误
+	refSchemaJSON["properties"].(map[string]interface{})["indexes"].(map[string]interface{})["items"] = map[string]interface{}{"$ref": "#/$defs/Missing"}
+	helper = NewSchemaHelper(refSchemaJSON)
+	paths, err = helper.RequiredPaths()
+	assert.Error(t, err)
+	assert.Nil(t, paths)
+}
+
+func TestSchemaHelper_Subschema(t *testing.T) {
+	refSchema := `{"$defs":{"ModelIndex":{"additionalProperties":false,"properties":{"field_name":{"items":{"type":"string"},"type":"array"},"type":{"type":"string"}},"type":"object"},"RawSchema":{"type":"object","widget":"RawJsonTree"}},"$id":"https://resok.cn/s/schemas/model","$schema":"https://json-schema.org/draft/2020-12/schema","additionalProperties":false,"properties":{"backend":{"default":"mongodb","enum":["mongodb"],"type":"string"},"desc":{"type":"string"},"fieldsDefine":{"$ref":"#/$defs/RawSchema"},"group":{"type":"string"},"indexes":{"items":{"$ref":"#/$defs/ModelIndex"},"type":"array"},"title":{"type":"string"},"user_id":{"type":"string"}},"required":["fieldsDefine","title"],"title":"模型","type":"object"}`
+	var refSchemaJSON map[string]interface{}
+	if err := json.Unmarshal([]byte(refSchema), &refSchemaJSON); err != nil {
+		t.Fatalf("failed to unmarshal schema: %v", err)
+	}
+	helper := NewSchemaHelper(refSchemaJSON)
+
+	sub, err := helper.Subschema("#/indexes")
+	assert.NoError(t, err)
+	expected := map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type":    "array",
+		"items":   map[string]interface{}{"$ref": "#/$defs/ModelIndex"},
+		"$defs": map[string]interface{}{
+			"ModelIndex": refSchemaJSON["$defs"].(map[string]interface{})["ModelIndex"],
+		},
+	}
+	assert.Equal(t, expected, sub)
+
+	// 修改子文档不会影响原schema
+	sub["$defs"].(map[string]interface{})["ModelIndex"].(map[string]interface{})["type"] = "changed"
+	assert.Equal(t, "object", refSchemaJSON["$defs"].(map[string]interface{})["ModelIndex"].(map[string]interface{})["type"])
+
+	sub, err = helper.Subschema("#/desc")
+	assert.NoError(t, err)
+	assert.NotContains(t, sub, "$defs")
+
+	_, err = helper.Subschema("#/missing")
+	assert.Error(t, err)
+}
+
+func TestSchemaHelper_SetByPointer(t *testing.T) {
+	refSchema := `{"$defs":{"ModelIndex":{"additionalProperties":false,"properties":{"field_name":{"items":{"type":"string"},"type":"array"},"type":{"type":"string"}},"type":"object"}},"properties":{"desc":{"type":"string"},"indexes":{"items":{"$ref":"#/$defs/ModelIndex"},"type":"array"},"pair":{"type":"array","items":[{"type":"string"},{"type":"number"}]}},"type":"object"}`
+	var refSchemaJSON map[string]interface{}
+	if err := json.Unmarshal([]byte(refSchema), &refSchemaJSON); err != nil {
+		t.Fatalf("failed to unmarshal schema: %v", err)
+	}
+	helper := NewSchemaHelper(refSchemaJSON)
+
+	// 替换属性
+	desc := map[string]any{"type": "string", "maxLength": 200}
+	assert.NoError(t, helper.SetByPointer("#/desc", desc))
+	got, err := helper.GetSchemaMapByPointer(helper.GetRaw(), "#/desc")
+	assert.NoError(t, err)
+	assert.Equal(t, desc, got)
+
+	// 经过 $ref 修改的是 $defs 中的定义
+	typ := map[string]any{"type": "string", "enum": []any{"unique", "text"}}
+	assert.NoError(t, helper.SetByPointer("/indexes/items/type", typ))
+	defs := helper.GetRaw()["$defs"].(map[string]interface{})
+	assert.Equal(t, typ, defs["ModelIndex"].(map[string]interface{})["properties"].(map[string]interface{})["type"])
+
+	// 元组与数组元素
+	assert.NoError(t, helper.SetByPointer("/pair/-1", map[string]any{"type": "integer"}))
+	got, err = helper.GetSchemaMapByPointer(helper.GetRaw(), "/pair/1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"type": "integer"}, got)
+
+	assert.Error(t, helper.SetByPointer("/missing", desc))
+	assert.Error(t, helper.SetByPointer("/pair/5", desc))
+	assert.Error(t, helper.SetByPointer("/", desc))
+}
+
+func TestSchemaHelper_EffectiveSchema(t *testing.T) {
+	refSchema := `{"$defs":{"Code":{"type":"string","minLength":1,"maxLength":20},"Owner":{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}},"type":"object","properties":{"code":{"$ref":"#/$defs/Code","description":"short code","allOf":[{"maxLength":8},{"minLength":2,"maxLength":12}]},"owner":{"allOf":[{"$ref":"#/$defs/Owner"},{"properties":{"email":{"type":"string"}},"required":["email"]}]},"plain":{"type":"integer"}}}`
+	var refSchemaJSON map[string]interface{}
+	if err := json.Unmarshal([]byte(refSchema), &refSchemaJSON); err != nil {
+		t.Fatalf("failed to unmarshal schema: %v", err)
+	}
+	helper := NewSchemaHelper(refSchemaJSON)
+
+	// type 来自 $ref maxLength 来自 allOf 成员 取最严格的值
+	code, err := helper.EffectiveSchema("#/code")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"type":        "string",
+		"description": "short code",
+		"minLength":   float64(2),
+		"maxLength":   float64(8),
+	}, code)
+
+	// allOf 中的 $ref 与 required 合并
+	owner, err := helper.EffectiveSchema("/owner")
+	assert.NoError(t, err)
+	assert.Equal(t, "object", owner["type"])
+	assert.Equal(t, []any{"name", "email"}, owner["required"])
+	assert.Len(t, owner["properties"], 2)
+
+	plain, err := helper.EffectiveSchema("/plain")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"type": "integer"}, plain)
+
+	_, err = helper.EffectiveSchema("/missing")
+	assert.Error(t, err)
+
+	// 原schema不会被修改
+	assert.Contains(t, refSchemaJSON["properties"].(map[string]any)["code"], "allOf")
+}
+
+func TestTypedAccessors(t *testing.T) {
+	var data map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"name": "John",
+		"age": 30,
+		"height": 1.82,
+		"pets": [{"name": "Fluffy"}, {"name": "Fido"}]
+	}`), &data))
+	data["count"] = 3
+	data["number"] = json.Number("42")
+
+	name, ok := GetString(data, "pets.1.name")
+	assert.True(t, ok)
+	assert.Equal(t, "Fido", name)
+	_, ok = GetString(data, "age")
+	assert.False(t, ok)
+	_, ok = GetString(data, "missing")
+	assert.False(t, ok)
+
+	age, ok := GetInt(data, "age")
+	assert.True(t, ok)
+	assert.Equal(t, int64(30), age)
+	count, ok := GetInt(data, "count")
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), count)
+	number, ok := GetInt(data, "number")
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), number)
+	_, ok = GetInt(data, "height")
+	assert.False(t, ok)
+	_, ok = GetInt(data, "name")
+	assert.False(t, ok)
+
+	height, ok := GetFloat(data, "height")
+	assert.True(t, ok)
+	assert.Equal(t, 1.82, height)
+	countFloat, ok := GetFloat(data, "count")
+	assert.True(t, ok)
+	assert.Equal(t, 3.0, countFloat)
+	_, ok = GetFloat(data, "pets")
+	assert.False(t, ok)
+
+	pets, ok := GetSlice(data, "pets")
+	assert.True(t, ok)
+	assert.Len(t, pets, 2)
+	names, ok := GetSlice(data, "pets.*.name")
+	assert.True(t, ok)
+	assert.Equal(t, []any{"Fluffy", "Fido"}, names)
+	_, ok = GetSlice(data, "name")
+	assert.False(t, ok)
+}
+
+func TestSchemaHelper_DiffData(t *testing.T) {
+	type Address struct {
+		City   string `json:"city"`
+		Street string `json:"street,omitempty"`
+	}
+	type Contact struct {
+		Name    string    `json:"name"`
+		Age     int       `json:"age"`
+		Tags    []string  `json:"tags"`
+		Address Address   `json:"address"`
+		Friends []Address `json:"friends"`
+		Note    string    `json:"note,omitempty"`
+	}
+
+	helper := NewSchemaHelper((&Reflector{}).Reflect(&Contact{}))
+	old := Contact{
+		Name:    "John",
+		Age:     30,
+		Tags:    []string{"a"},
+		Address: Address{City: "Paris", Street: "Rue"},
+		Friends: []Address{{City: "Rome"}},
+	}
+	updated := old
+	updated.Age = 31
+	updated.Tags = []string{"a", "b"}
+	updated.Address = Address{City: "Paris"}
+	updated.Friends = []Address{{City: "Oslo"}}
+	updated.Note = "moved"
+
+	changes := helper.DiffData(old, updated)
+	assert.Equal(t, []DataChange{
+		{Path: "address.street", Kind: DataRemoved, Old: "Rue"},
+		{Path: "age", Kind: DataChanged, Old: float64(30), New: float64(31)},
+		{Path: "friends.*.city", Kind: DataChanged, Old: []any{"Rome"}, New: []any{"Oslo"}},
+		{Path: "note", Kind: DataAdded, New: "moved"},
+		{Path: "tags", Kind: DataChanged, Old: []any{"a"}, New: []any{"a", "b"}},
+	}, changes)
+
+	assert.Empty(t, helper.DiffData(old, old))
+
+	// 空数组是一个值 只有缺失的字段才算不存在
+	emptied := old
+	emptied.Tags = []string{}
+	assert.Equal(t, []DataChange{
+		{Path: "tags", Kind: DataChanged, Old: []any{"a"}, New: []any{}},
+	}, helper.DiffData(old, emptied))
+	missing := emptied
+	missing.Tags = nil
+	assert.Equal(t, []DataChange{
+		{Path: "tags", Kind: DataRemoved, Old: []any{}},
+	}, helper.DiffData(emptied, missing))
+}
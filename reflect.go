@@ -8,14 +8,21 @@ package jsonschema
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
 	"github.com/iancoleman/orderedmap"
+	"math/big"
 	"net"
+	"net/netip"
 	"net/url"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 // Version is the JSON Schema version.
@@ -55,6 +62,7 @@ type Schema struct {
 	PropertyNames        *Schema                `json:"propertyNames,omitempty" bson:"property_names,omitempty"`               // section 10.3.2.4
 	// RFC draft-bhutton-json-schema-validation-00, section 6
 	Type              string              `json:"type,omitempty" bson:"type,omitempty"`                            // section 6.1.1
+	Types             []string            `json:"-" bson:"types,omitempty"`                                        // section 6.1.1 (array form, replaces Type, written as JSON "type")
 	Enum              []interface{}       `json:"enum,omitempty" bson:"enum,omitempty"`                            // section 6.1.2
 	Const             interface{}         `json:"const,omitempty" bson:"const,omitempty"`                          // section 6.1.3
 	MultipleOf        int                 `json:"multipleOf,omitempty" bson:"multiple_of,omitempty"`               // section 6.2.1
@@ -97,8 +105,15 @@ type Schema struct {
 	// 额外注入的内容
 	MetaData map[string]interface{} `json:"meta_data,omitempty" bson:"meta_data,omitempty"`
 
+	// KeepEmpty 为true时 空的schema序列化为 {} 而不是 true
+	// 用于先创建占位 之后再填充的场景
+	KeepEmpty bool `json:"-" bson:"-"`
+
 	// Special boolean representation of the Schema - section 4.3.2
 	boolean *bool `bson:"boolean,omitempty"`
+
+	// union 标签所属的组名 用于定位 allOf 中的分组 不依赖输出的标题
+	unionGroup string
 }
 
 var (
@@ -144,6 +159,12 @@ func ReflectFromType(t reflect.Type) *Schema {
 	return r.ReflectFromType(t)
 }
 
+// TypeFormat is the type and format pair used by the Reflector's FormatMapper.
+type TypeFormat struct {
+	Type   string
+	Format string
+}
+
 // A Reflector reflects values into a Schema.
 type Reflector struct {
 	// BaseSchemaID defines the URI that will be used as a base to determine Schema
@@ -160,6 +181,15 @@ type Reflector struct {
 	// known as an "anonymous schema". As a rule, this is not recommended.
 	Anonymous bool
 
+	// OmitVersion when true will leave out the `$schema` keyword from the root
+	// schema, for schemas embedded into a larger document such as the components
+	// of an OpenAPI specification.
+	OmitVersion bool
+
+	// SchemaVersion overrides the package level Version for this Reflector,
+	// both as the `$schema` of root schemas and to pick the draft specific rules.
+	SchemaVersion string
+
 	// AssignAnchor when true will use the original struct's name as an anchor inside
 	// every definition, including the root schema. These can be useful for having a
 	// reference to the original struct's name in CamelCase instead of the snake-case used
@@ -176,11 +206,41 @@ type Reflector struct {
 	// validated JSON is unmarshaled.
 	AllowAdditionalProperties bool
 
+	// IncludeJSONDashWithSchemaTag when true will still emit fields tagged with
+	// `json:"-"` if they also have a non-empty `jsonschema` tag, using the field's
+	// name. Useful to document derived fields, they are never required.
+	IncludeJSONDashWithSchemaTag bool
+
 	// RequiredFromJSONSchemaTags will cause the Reflector to generate a schema
 	// that requires any key tagged with `jsonschema:required`, overriding the
 	// default of requiring any key *not* tagged with `json:,omitempty`.
 	RequiredFromJSONSchemaTags bool
 
+	// RequiredFromValidateTag will cause the Reflector to require any key whose
+	// `validate` tag, as used by go-playground/validator, contains the `required`
+	// rule, overriding the default of requiring any key *not* tagged with
+	// `json:,omitempty`. Combined with RequiredFromJSONSchemaTags, a key is
+	// required when either tag asks for it.
+	RequiredFromValidateTag bool
+
+	// ImportValidateTags will translate the common go-playground/validator rules
+	// found in `validate` tags into schema keywords. The supported subset is:
+	//
+	//	min, max, len     minLength/maxLength, minimum/maximum, minItems/maxItems
+	//	                  or minProperties/maxProperties depending on the type
+	//	gte, lte          minimum/maximum on numbers
+	//	oneof             enum, values are separated by spaces
+	//	email, uri, uuid, hostname, ipv4, ipv6
+	//	                  the matching format, `url` maps to `uri`
+	//
+	// Rules after `dive` apply to elements and are ignored, as are unknown rules.
+	ImportValidateTags bool
+
+	// SortRequired will sort the `required` list of each struct alphabetically
+	// instead of following the order in which fields are declared, keeping
+	// the output stable when fields are reordered.
+	SortRequired bool
+
 	// Do not reference definitions. This will remove the top-level $defs map and
 	// instead cause the entire structure of types to be output in one tree. The
 	// list of type definitions (`$defs`) will not be included.
@@ -191,7 +251,9 @@ type Reflector struct {
 	ExpandedStruct bool
 
 	// IgnoredTypes defines a slice of types that should be ignored in the schema,
-	// switching to just allowing additional properties instead.
+	// switching to just allowing additional properties instead. Use it for other
+	// non-serializable types, fields of the `sync` and `sync/atomic` packages are
+	// always skipped.
 	IgnoredTypes []interface{}
 
 	// Lookup allows a function to be defined that will provide a custom mapping of
@@ -203,10 +265,20 @@ type Reflector struct {
 	// Mapper is a function that can be used to map custom Go types to jsonschema schemas.
 	Mapper func(reflect.Type) *Schema
 
+	// FormatMapper maps custom scalar types to a simple type and format pair, such
+	// as a `Date` type to `{"type":"string","format":"date"}`. It is a lighter
+	// alternative to Mapper and is consulted right after it.
+	FormatMapper map[reflect.Type]TypeFormat
+
 	// Intercept 拦截器 可返回false拦截生成
 	// 用例在于 传入同一个struct 不同的情况可能会跳过某些字段的生成 但又不能设置 json标签为-
 	Intercept func(reflect.StructField) bool
 
+	// Profile 当前生成的场景 设置后只会生成 profiles 标签包含该值的字段
+	// eg: `jsonschema:"profiles=public;internal"` 未设置 profiles 标签的字段始终生成
+	// 基于 Intercept 的拦截逻辑 两者可以同时使用
+	Profile string
+
 	// Namer allows customizing of type names. The default is to use the type's name
 	// provided by the reflect package.
 	Namer func(reflect.Type) string
@@ -216,6 +288,10 @@ type Reflector struct {
 	// If a json tag is present, KeyNamer will receive the tag's name as an argument, not the original key name.
 	KeyNamer func(string) string
 
+	// KeyNamerFull works like KeyNamer but also receives the struct field, so the
+	// key can depend on the field's type or tags. It takes precedence over KeyNamer.
+	KeyNamerFull func(f reflect.StructField, name string) string
+
 	// AdditionalFields allows adding structfields for a given type
 	AdditionalFields func(reflect.Type) []reflect.StructField
 
@@ -235,15 +311,210 @@ type Reflector struct {
 	// See also: AddGoComments
 	CommentMap map[string]string
 
+	// TitleFromComment when true will use the first sentence of a type's comment
+	// from the CommentMap as its title, and the rest as its description. Types
+	// that already have a title keep it along with their whole comment.
+	TitleFromComment bool
+
+	// DescriptionMaxLength when greater than zero will truncate descriptions taken
+	// from the CommentMap to the given number of characters, adding an ellipsis.
+	// Descriptions set explicitly through tags are never truncated.
+	DescriptionMaxLength int
+
+	// DescriptionResolver when set looks up the description of fields with a
+	// `desc_key=` tag keyword, such as a message id from translated resources.
+	// The description from tags or comments is kept when it returns an empty string.
+	DescriptionResolver func(key string) string
+
 	// TagMapper 自定义解析tag对应的处理函数
 	TagMapper map[string]TagMapperFunc
 
 	// DoNotBase64 禁用base64的判断 用于区分定义中的 []uint8和 []byte相同的窘境
 	DoNotBase64 bool
 
+	// SetsAsArrays when true will reflect the set idiom `map[T]struct{}` as an
+	// array of unique T items. encoding/json still marshals these maps as objects
+	// with empty values, so the set type needs a MarshalJSON producing the array.
+	SetsAsArrays bool
+
+	// SharedByteSchema when true will reflect every `[]byte` as a reference to a
+	// single `Base64Bytes` definition instead of repeating the base64 string schema
+	// inline. Tag keywords such as `minBytes` can't apply to the shared definition
+	// and are ignored. It has no effect with DoNotReference or DoNotBase64. If a
+	// type of that name is also reflected, whichever comes second gets a suffix.
+	SharedByteSchema bool
+
+	// URLFormat overrides the `uri` format used for `url.URL` fields, for example
+	// with `uri-reference` when they usually hold relative references.
+	URLFormat string
+
+	// TitleFromName when true will set the title of every definition without one
+	// to its humanized type name, such as `UserProfile` to `User Profile`.
+	TitleFromName bool
+
+	// AnnotateProvenance when true will add to the `$comment` of every definition
+	// the fully qualified name of its Go type, such as
+	// `github.com/org/app/models.User`, to trace a definition back to the code.
+	AnnotateProvenance bool
+
+	// AutoFormat when true will try to infer the `format` of string fields without
+	// one from their names, such as `Email` to `email` or `URL` to `uri`.
+	AutoFormat bool
+
+	// FormatGuesser replaces DefaultFormatGuesser when AutoFormat is enabled. An
+	// empty string means no format could be inferred.
+	FormatGuesser func(reflect.StructField) string
+
+	// WriteOnlyByName is a list of case-insensitive field name substrings, such as
+	// "password" or "secret", whose properties will be flagged as writeOnly.
+	WriteOnlyByName []string
+
+	// SchemaTagKey overrides the struct tag key used to read schema keywords,
+	// defaults to `jsonschema`. The `_description` and `_extras` variants will
+	// use the same prefix, so a key of `js` reads `js_description` and `js_extras`.
+	SchemaTagKey string
+
+	// BigIntAsString when true will reflect `big.Int` as a string of digits instead
+	// of a number, for consumers that would otherwise lose precision.
+	BigIntAsString bool
+
+	// NamedScalarsAsDefs when true will add named scalar types, such as
+	// `type Email string`, to the $defs and reference them by name instead of
+	// inlining a bare scalar schema wherever they are used. Tag keywords of a
+	// field, such as `minLength` or `enum`, are kept next to its `$ref`, or in
+	// an `allOf` for drafts ignoring `$ref` siblings.
+	NamedScalarsAsDefs bool
+
+	// IgnoreCustomTags when true will skip all the `jsonschema`, `jsonschema_extras`,
+	// `jsonschema_description` and TagMapper processing on struct fields, producing a
+	// pure validation schema. Property names and required rules from `json` tags
+	// are still honored.
+	IgnoreCustomTags bool
+
+	// OpenAPINullable when true will mark `nullable` fields with a `nullable: true`
+	// keyword alongside their own type, as understood by OpenAPI 3.0 tooling,
+	// instead of wrapping them in a `oneOf` with the `null` type. References are
+	// wrapped in an `allOf` as siblings of `$ref` are ignored in OpenAPI 3.0.
+	OpenAPINullable bool
+
+	// MaxEnumValues when greater than zero limits how many values an `enum` may
+	// list. Larger enums are removed and noted in the `$comment` with how many
+	// values were omitted, keeping the type and any other keywords intact.
+	MaxEnumValues int
+
+	// DropOversizedEnums when true removes enums exceeding MaxEnumValues without
+	// leaving a `$comment` behind, producing a bare schema of the value's type.
+	DropOversizedEnums bool
+
+	// EnumExamples when true copies the values of every `enum` into `examples`,
+	// for documentation tools that don't render enums. Schemas that already have
+	// examples keep them. Enums removed by MaxEnumValues are not copied.
+	EnumExamples bool
+
+	// AnonymousStructDefs when true will add inline anonymous struct types to the
+	// definitions under a stable name derived from a hash of their shape, such as
+	// `Anon1a2b3c4d5e6f7a8b`, so that fields sharing the same shape reference one
+	// entry. Different shapes whose names would collide get a numeric suffix.
+	AnonymousStructDefs bool
+
+	// ExamplesSeparator is used to split the values of an `examples=` tag keyword
+	// into several examples, `|` when empty. A separator preceded by a backslash is
+	// kept as part of the value.
+	ExamplesSeparator string
+
+	// MaxProperties when greater than zero caps how many properties are reflected
+	// for a single struct, as a safety measure when reflecting untrusted types.
+	// Remaining fields are skipped without being reflected and a `$comment`
+	// records the truncation.
+	MaxProperties int
+
+	// PreserveGoFieldName will record the original Go field name of each property
+	// in an `x-go-name` keyword, helping to map a renamed key back to the code.
+	PreserveGoFieldName bool
+
+	// ErrorAsString will reflect `error` typed fields as strings, matching the
+	// common practice of serializing errors through their Error() message,
+	// instead of the empty schema used for other interfaces.
+	ErrorAsString bool
+
+	// NonEmptyRequiredSlices will set `minItems` to 1 on required slice fields
+	// without one, as a `[]T` field always emitted is often meant to hold at least
+	// one item. This is a heuristic: nil or empty slices still marshal to `null`
+	// or `[]`, so only enable it when the code guarantees non-empty values.
+	NonEmptyRequiredSlices bool
+
+	// StringerAsString will reflect types implementing fmt.Stringer as plain
+	// strings, for domain types serialized through their String() form by a
+	// custom MarshalJSON. Reflection can't verify what MarshalJSON produces, so
+	// types printing one way and marshaling another, like time.Duration which is
+	// marshaled as an integer, will be described wrongly. time.Time and url.URL
+	// keep their formats, and a JSONSchema method always takes precedence.
+	StringerAsString bool
+
+	// ContentAddressedDefs when true will name every definition after a hash of
+	// its serialized schema, such as `def_1a2b3c4d5e6f7a8b`, instead of its Go
+	// type name. Structurally identical types then share a single definition.
+	// Names of recursive definitions are stable but may not collapse.
+	ContentAddressedDefs bool
+
 	// Modifier 修改器可以修改最后生成的schema
 	// fieldName 是会在parent的 Properties中 新增的key名称
 	Modifier func(now *Schema, structField reflect.StructField, parent *Schema, parentType reflect.Type, fieldName string)
+
+	// OnDefinition 在每个定义完整生成之后调用一次 可用于附加类型级别的元数据或检查约束
+	// name 是该定义在 $defs 中的名称
+	OnDefinition func(name string, s *Schema, t reflect.Type)
+
+	// OnRequiredWithDefault 在字段既是必填又设置了 default 时调用 两者是矛盾的 通常是书写错误
+	// 返回 false 会将该字段从 required 中移除 返回 true 则保持必填 可在其中记录警告
+	OnRequiredWithDefault func(parentType reflect.Type, f reflect.StructField, fieldName string) bool
+
+	// StrictTags will cause ReflectSafe to fail on `jsonschema` tags that would
+	// otherwise be silently ignored or resolved by guessing:
+	//
+	//   - unknown keywords, malformed values and duplicated keywords, see ValidateTag
+	//   - `type` combined with `oneof_type` or `anyof_type`, or both of the latter
+	//   - `enum`, `enum_titled` or `not_const` on a field that isn't a string, integer or number
+	//   - keywords meant for another type, such as `minLength` on an integer
+	//   - fields both readOnly and writeOnly, including through WriteOnlyByName
+	StrictTags bool
+
+	// registry of types added with RegisterType
+	registry map[string]reflect.Type
+
+	// tagErrors collects the StrictTags problems found during ReflectSafe
+	tagErrors *[]error
+
+	// inProgress holds the names of the definitions still being reflected, to
+	// reference recursive types under DoNotReference. It is set per call by forCall.
+	inProgress map[string]bool
+
+	// claimedNames maps the definition names made up by the reflector, for
+	// anonymous structs under AnonymousStructDefs and the SharedByteSchema
+	// definition, to their types, so other types landing on one get a suffix.
+	// It is set per call by forCall.
+	claimedNames map[string]reflect.Type
+}
+
+// ReflectSafe reflects the value like Reflect but returns an error instead of
+// panicking on unsupported types. With StrictTags the tag problems found while
+// reflecting are returned as TagErrors.
+func (r *Reflector) ReflectSafe(v interface{}) (s *Schema, err error) {
+	rc := *r
+	var tagErrors []error
+	if r.StrictTags {
+		rc.tagErrors = &tagErrors
+	}
+	defer func() {
+		if rec := recover(); rec != nil {
+			s, err = nil, fmt.Errorf("jsonschema: %v", rec)
+		}
+	}()
+	s = rc.Reflect(v)
+	if len(tagErrors) > 0 {
+		return nil, TagErrors(tagErrors)
+	}
+	return s, nil
 }
 
 // Reflect reflects to Schema from a value.
@@ -251,16 +522,47 @@ func (r *Reflector) Reflect(v interface{}) *Schema {
 	return r.ReflectFromType(reflect.TypeOf(v))
 }
 
+// ReflectSplit reflects the type like ReflectFromType but returns the `$defs`
+// separately from the root schema, allowing the definitions of several
+// reflections to be merged into a single document.
+func (r *Reflector) ReflectSplit(t reflect.Type) (root *Schema, defs Definitions) {
+	root = r.ReflectFromType(t)
+	defs = root.Definitions
+	root.Definitions = nil
+	return root, defs
+}
+
+// forCall returns a copy of the reflector holding the state of a single call,
+// so a Reflector can be shared and a panic leaves no state behind.
+func (r *Reflector) forCall() *Reflector {
+	c := *r
+	c.inProgress = map[string]bool{}
+	c.claimedNames = map[string]reflect.Type{}
+	if c.SchemaVersion == "" {
+		c.SchemaVersion = Version
+	}
+	return &c
+}
+
 // ReflectFromType generates root schema
 func (r *Reflector) ReflectFromType(t reflect.Type) *Schema {
-	if t.Kind() == reflect.Ptr {
+	r = r.forCall()
+	definitions := Definitions{}
+	s := r.reflectRoot(definitions, t)
+	r.finish(s, definitions)
+	return s
+}
+
+// reflectRoot reflects the root schema of the type into the definitions of
+// the current call, leaving the document wide passes to finish.
+func (r *Reflector) reflectRoot(definitions Definitions, t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
 		t = t.Elem() // re-assign from pointer
 	}
 
 	name := r.typeName(t)
 
 	s := new(Schema)
-	definitions := Definitions{}
 	s.Definitions = definitions
 	bs := r.reflectTypeToSchemaWithID(definitions, t)
 	if r.ExpandedStruct {
@@ -287,14 +589,372 @@ func (r *Reflector) ReflectFromType(t reflect.Type) *Schema {
 		}
 	}
 
-	s.Version = Version
+	s.Version = r.version()
+	return s
+}
+
+// finish runs the passes applying to the whole document once everything has
+// been reflected, shared by every entry point, and sets the definitions of the
+// root schema: only the referenced ones with DoNotReference.
+func (r *Reflector) finish(s *Schema, definitions Definitions) {
+	if r.MaxEnumValues > 0 {
+		r.limitEnums(s)
+		for _, def := range definitions {
+			r.limitEnums(def)
+		}
+	}
+	if r.EnumExamples {
+		enumExamples(s)
+		for _, def := range definitions {
+			enumExamples(def)
+		}
+	}
+
+	if r.ContentAddressedDefs {
+		definitions = contentAddressDefinitions(s, definitions)
+	}
+
 	if !r.DoNotReference {
 		s.Definitions = definitions
+	} else {
+		s.Definitions = referencedDefinitions(s, definitions)
+	}
+}
+
+// RegisterType makes the type of the provided value available to ReflectByName
+// under the given name, replacing any type previously registered with it.
+func (r *Reflector) RegisterType(name string, v interface{}) {
+	if r.registry == nil {
+		r.registry = make(map[string]reflect.Type)
+	}
+	r.registry[name] = reflect.TypeOf(v)
+}
+
+// ReflectByName generates the root schema of a type added with RegisterType.
+func (r *Reflector) ReflectByName(name string) (*Schema, error) {
+	t, ok := r.registry[name]
+	if !ok {
+		return nil, fmt.Errorf("type %q is not registered", name)
+	}
+	return r.ReflectFromType(t), nil
+}
+
+// ReflectWithExamples reflects the type of the first provided value and adds the
+// distinct, non-zero values found in each field of all the values to the
+// `examples` of the matching property. Returns nil when no values are given.
+func (r *Reflector) ReflectWithExamples(examples ...interface{}) *Schema {
+	if len(examples) == 0 {
+		return nil
+	}
+	s := r.Reflect(examples[0])
+
+	target := s
+	if strings.HasPrefix(s.Ref, "#/$defs/") {
+		target = s.Definitions[strings.TrimPrefix(s.Ref, "#/$defs/")]
+	}
+	if target == nil || target.Properties == nil {
+		return s
+	}
+
+	keys := map[string]string{}
+	r.exampleKeys(reflect.TypeOf(examples[0]), keys)
+	for _, example := range examples {
+		values, err := StructToMap(example)
+		if err != nil {
+			continue
+		}
+		for jsonKey, key := range keys {
+			val, ok := values[jsonKey]
+			if !ok || isZeroExample(val) {
+				continue
+			}
+			v, _ := target.Properties.Get(key)
+			property, ok := v.(*Schema)
+			if !ok {
+				continue
+			}
+			property.addUniqueExample(val)
+		}
+	}
+	return s
+}
+
+// exampleKeys maps the keys of the JSON encoding of the struct to the keys of
+// the properties reflected for its fields, which differ with a KeyNamer.
+func (r *Reflector) exampleKeys(t reflect.Type, keys map[string]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, shouldEmbed, _, _ := r.reflectFieldName(f)
+		if name == "" {
+			if shouldEmbed {
+				r.exampleKeys(f.Type, keys)
+			}
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		jsonKey := strings.Split(tag, ",")[0]
+		if jsonKey == "" {
+			jsonKey = f.Name
+		}
+		keys[jsonKey] = name
+	}
+}
+
+func isZeroExample(val interface{}) bool {
+	switch v := val.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case float64:
+		return v == 0
+	case bool:
+		return !v
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func (t *Schema) addUniqueExample(val interface{}) {
+	for _, existing := range t.Examples {
+		if reflect.DeepEqual(existing, val) {
+			return
+		}
+	}
+	t.Examples = append(t.Examples, val)
+}
+
+// ReflectFunc describes the parameters of the provided function as an object
+// schema, useful for RPC or tool calling definitions. Each parameter becomes a
+// required property named after the matching entry of names, or `argN` when
+// not provided.
+func (r *Reflector) ReflectFunc(fn interface{}, names ...string) (*Schema, error) {
+	r = r.forCall()
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func {
+		return nil, fmt.Errorf("expected a function, got %v", t)
+	}
+
+	definitions := Definitions{}
+	s := &Schema{
+		Version:    r.version(),
+		Type:       "object",
+		Properties: orderedmap.New(),
+	}
+	if !r.AllowAdditionalProperties {
+		s.AdditionalProperties = FalseSchema
+	}
+	for i := 0; i < t.NumIn(); i++ {
+		name := "arg" + strconv.Itoa(i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		s.Properties.Set(name, r.refOrReflectTypeToSchema(definitions, t.In(i)))
+		s.Required = append(s.Required, name)
+	}
+	r.finish(s, definitions)
+	if len(s.Definitions) == 0 {
+		s.Definitions = nil
+	}
+	return s, nil
+}
+
+// ReflectQueryParams reflects a struct describing query-string parameters, where
+// every value arrives as a string. The schema is inlined and scalar properties,
+// including the items of repeated parameters, are turned into strings hinting
+// at the original type: integers and numbers get a numeric `pattern` and
+// booleans an enum of "true" and "false".
+func (r *Reflector) ReflectQueryParams(v interface{}) *Schema {
+	rc := *r
+	rc.DoNotReference = true
+	s := rc.Reflect(v)
+	s.Definitions = nil
+	if s.Properties != nil {
+		for _, key := range s.Properties.Keys() {
+			p, _ := s.Properties.Get(key)
+			if ps, ok := p.(*Schema); ok {
+				s.Properties.Set(key, queryParamSchema(ps))
+			}
+		}
+	}
+	return s
+}
+
+// queryParamSchema converts a scalar schema into its query-string form.
+func queryParamSchema(s *Schema) *Schema {
+	var pattern string
+	switch s.Type {
+	case "array":
+		if s.Items != nil {
+			items := *s
+			items.Items = queryParamSchema(s.Items)
+			return &items
+		}
+		return s
+	case "integer":
+		pattern = "^-?[0-9]+$"
+	case "number":
+		pattern = "^-?[0-9]+(\\.[0-9]+)?$"
+	case "boolean":
+	default:
+		return s
+	}
+
+	qs := &Schema{
+		Type:        "string",
+		Pattern:     pattern,
+		Title:       s.Title,
+		Description: s.Description,
+		Deprecated:  s.Deprecated,
+		Extras:      s.Extras,
+	}
+	if s.Type == "boolean" {
+		qs.Enum = []interface{}{"true", "false"}
+	}
+	for _, e := range s.Enum {
+		qs.Enum = append(qs.Enum, fmt.Sprint(e))
 	}
+	if s.Default != nil {
+		qs.Default = fmt.Sprint(s.Default)
+	}
+	return qs
+}
+
+// ReflectSlice generates a root schema describing a JSON array whose items
+// are of the provided element's type, with the element added to the $defs.
+func (r *Reflector) ReflectSlice(elem interface{}) *Schema {
+	r = r.forCall()
+	definitions := Definitions{}
+	s := &Schema{
+		Version: r.version(),
+		Type:    "array",
+		Items:   r.refOrReflectTypeToSchema(definitions, reflect.TypeOf(elem)),
+	}
+	r.finish(s, definitions)
+	return s
+}
+
+// ReflectMany reflects all the provided values into a single document with a
+// shared $defs map, so common sub-types are only defined once. The root schema
+// will match any of the provided types using `oneOf`.
+func (r *Reflector) ReflectMany(vs ...interface{}) *Schema {
+	r = r.forCall()
+	definitions := Definitions{}
+	s := &Schema{
+		Version: r.version(),
+	}
+	for _, v := range vs {
+		s.OneOf = append(s.OneOf, r.refOrReflectTypeToSchema(definitions, reflect.TypeOf(v)))
+	}
+	r.finish(s, definitions)
+	return s
+}
+
+// ReflectEnvelope reflects the envelope, a standard response shape such as
+// `{data, error, meta}`, with the schema of the property of its Go field named
+// field replaced by the reflected payload. This avoids declaring an envelope
+// type for every payload type. The property keeps the key the envelope field
+// is reflected under, and an error is returned when there is no such property.
+func (r *Reflector) ReflectEnvelope(payload interface{}, envelope interface{}, field string) (*Schema, error) {
+	et := reflect.TypeOf(envelope)
+	for et != nil && et.Kind() == reflect.Ptr {
+		et = et.Elem()
+	}
+	if et == nil || et.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct envelope, got %v", et)
+	}
+	f, ok := et.FieldByName(field)
+	if !ok {
+		return nil, fmt.Errorf("envelope %v has no field %s", et, field)
+	}
+
+	// the envelope and the payload share one call, so their definitions are
+	// named together and the document wide passes run once over both
+	r = r.forCall()
+	definitions := Definitions{}
+	s := r.reflectRoot(definitions, et)
+	key, _, _, _ := r.reflectFieldName(f)
+	obj := s
+	if name := strings.TrimPrefix(s.Ref, "#/$defs/"); name != s.Ref && definitions[name] != nil {
+		obj = definitions[name]
+	}
+	exists := false
+	if key != "" && obj.Properties != nil {
+		_, exists = obj.Properties.Get(key)
+	}
+	if !exists {
+		return nil, fmt.Errorf("envelope %v field %s is not reflected as a property", et, field)
+	}
+	obj.Properties.Set(key, r.refOrReflectTypeToSchema(definitions, reflect.TypeOf(payload)))
+	r.finish(s, definitions)
+	return s, nil
+}
 
+// ReflectPatch reflects the value into a schema for JSON Merge Patch (RFC 7396)
+// documents, as sent to PATCH endpoints: nothing is required anymore and
+// scalar properties also accept null, used to clear them. Groups that only
+// required some properties, from `oneof_required` or `union` tags, are dropped.
+func (r *Reflector) ReflectPatch(v interface{}) *Schema {
+	s := r.Reflect(v)
+	visited := map[*Schema]bool{}
+	var walk func(t *Schema)
+	walk = func(t *Schema) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		for _, sub := range t.subSchemas() {
+			walk(sub)
+		}
+		t.Required = nil
+		t.AllOf = withoutTitleOnly(t.AllOf)
+		t.AnyOf = withoutTitleOnly(t.AnyOf)
+		t.OneOf = withoutTitleOnly(t.OneOf)
+		if t.Properties == nil {
+			return
+		}
+		for _, key := range t.Properties.Keys() {
+			v, _ := t.Properties.Get(key)
+			if p, ok := v.(*Schema); ok && p.Ref == "" && isScalarType(p.Type) {
+				t.Properties.Set(key, r.nullableSchema(p))
+			}
+		}
+	}
+	walk(s)
 	return s
 }
 
+// withoutTitleOnly removes the schemas left with nothing but a title.
+func withoutTitleOnly(list []*Schema) []*Schema {
+	var res []*Schema
+	for _, s := range list {
+		if !reflect.DeepEqual(s, &Schema{Title: s.Title, unionGroup: s.unionGroup}) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func isScalarType(typ string) bool {
+	switch typ {
+	case "string", "number", "integer", "boolean":
+		return true
+	}
+	return false
+}
+
 // Definitions hold schema definitions.
 // http://json-schema.org/latest/json-schema-validation.html#rfc.section.5.26
 // RFC draft-wright-json-schema-validation-00, section 5.26
@@ -314,6 +974,27 @@ var byteSliceType = reflect.TypeOf([]byte(nil))
 // Except for json.RawMessage
 var rawMessageType = reflect.TypeOf(json.RawMessage{})
 
+// json.Number is a string underneath but holds any JSON number
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+
+// math/big numbers are structs but serialize as plain numbers
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+)
+
+// error values are usually serialized through their Error() message
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// StringerAsString reflects implementations as strings
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+// net/netip types are structs but serialize as their text form
+var (
+	netipAddrType   = reflect.TypeOf(netip.Addr{})
+	netipPrefixType = reflect.TypeOf(netip.Prefix{})
+)
+
 // Go code generated from protobuf enum types should fulfil this interface.
 type protoEnum interface {
 	EnumDescriptor() ([]byte, []int)
@@ -340,6 +1021,14 @@ func (r *Reflector) refOrReflectTypeToSchema(definitions Definitions, t reflect.
 		return def
 	}
 
+	// Recursive types can't be inlined, reference them even with DoNotReference
+	if r.DoNotReference {
+		name := r.typeName(t)
+		if _, ok := definitions[name]; ok && r.inProgress[name] {
+			return &Schema{Ref: "#/$defs/" + name}
+		}
+	}
+
 	return r.reflectTypeToSchemaWithID(definitions, t)
 }
 
@@ -368,6 +1057,9 @@ func (r *Reflector) reflectTypeToSchema(definitions Definitions, t reflect.Type)
 			return t
 		}
 	}
+	if tf, ok := r.FormatMapper[t]; ok {
+		return &Schema{Type: tf.Type, Format: tf.Format}
+	}
 	if rt := r.reflectCustomSchema(definitions, t); rt != nil {
 		return rt
 	}
@@ -395,6 +1087,67 @@ func (r *Reflector) reflectTypeToSchema(definitions Definitions, t reflect.Type)
 		return st
 	}
 
+	if t == errorType && r.ErrorAsString {
+		st.Type = "string"
+		return st
+	}
+
+	// netip.Addr may hold either an IPv4 or an IPv6 address
+	switch t {
+	case netipAddrType:
+		st.Type = "string"
+		st.AnyOf = []*Schema{
+			{Format: "ipv4"},
+			{Format: "ipv6"},
+		}
+		return st
+	case netipPrefixType:
+		// CIDR notation such as 192.168.0.0/24, there is no standard format for it
+		st.Type = "string"
+		return st
+	}
+
+	// json.Number accepts both integers and floats, use `type=integer` to narrow it
+	if t == jsonNumberType {
+		st.Type = "number"
+		return st
+	}
+
+	// Arbitrary precision numbers, their internal fields are never serialized
+	switch t {
+	case bigIntType:
+		if r.BigIntAsString {
+			st.Type = "string"
+			st.Pattern = "^-?[0-9]+$"
+		} else {
+			st.Type = "number"
+		}
+		return st
+	case bigFloatType:
+		st.Type = "number"
+		return st
+	}
+
+	if t == byteSliceType && r.SharedByteSchema && !r.DoNotReference && !r.DoNotBase64 {
+		name := r.claimName("Base64Bytes", t, definitions)
+		if definitions[name] == nil {
+			bytes := &Schema{Type: "string", ContentEncoding: "base64"}
+			r.addNamedDefinition(definitions, name, t, bytes)
+			r.namedDefinitionDone(definitions, name, t, bytes)
+		}
+		return &Schema{Ref: "#/$defs/" + name}
+	}
+
+	if r.StringerAsString && t.Kind() != reflect.Interface && t != timeType && t != uriType &&
+		(t.Implements(stringerType) || reflect.PtrTo(t).Implements(stringerType)) {
+		st.Type = "string"
+		return st
+	}
+
+	name := r.typeName(t)
+	if name != "" {
+		r.inProgress[name] = true
+	}
 	switch t.Kind() {
 	case reflect.Struct:
 		r.reflectStruct(definitions, t, st)
@@ -424,8 +1177,19 @@ func (r *Reflector) reflectTypeToSchema(definitions Definitions, t reflect.Type)
 	default:
 		panic("unsupported type " + t.String())
 	}
+	delete(r.inProgress, name)
+
+	if r.NamedScalarsAsDefs && st.Type != "" && t.PkgPath() != "" {
+		switch t.Kind() {
+		case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		default:
+			r.addDefinition(definitions, t, st)
+			r.typeComment(t, st)
+		}
+	}
 
 	r.reflectSchemaExtend(definitions, t, st)
+	r.definitionDone(definitions, t, st)
 
 	// Always try to reference the definition which may have just been created
 	if def := r.refDefinition(definitions, t); def != nil {
@@ -441,10 +1205,20 @@ func (r *Reflector) reflectCustomSchema(definitions Definitions, t reflect.Type)
 	}
 
 	if t.Implements(customType) {
+		// Reuse an existing definition instead of calling JSONSchema again
+		if ref := r.refDefinition(definitions, t); ref != nil {
+			return ref
+		}
 		v := reflect.New(t)
 		o := v.Interface().(customSchemaImpl)
 		st := o.JSONSchema()
+		// A schema that is only a reference, such as one to an external document,
+		// is used as is instead of being wrapped in a definition of its own
+		if st != nil && st.Ref != "" && reflect.DeepEqual(st, &Schema{Ref: st.Ref}) {
+			return st
+		}
 		r.addDefinition(definitions, t, st)
+		r.definitionDone(definitions, t, st)
 		if ref := r.refDefinition(definitions, t); ref != nil {
 			return ref
 		}
@@ -475,7 +1249,7 @@ func (r *Reflector) reflectSliceOrArray(definitions Definitions, t reflect.Type,
 	r.addDefinition(definitions, t, st)
 
 	if st.Description == "" {
-		st.Description = r.lookupComment(t, "")
+		r.typeComment(t, st)
 	}
 
 	if t.Kind() == reflect.Array {
@@ -485,7 +1259,7 @@ func (r *Reflector) reflectSliceOrArray(definitions Definitions, t reflect.Type,
 	// 这里有问题 用[]byte是[]uint8的别名 所以[]uint8会被命中规则 在某些场景不友好
 	if t.Kind() == reflect.Slice && t.Elem() == byteSliceType.Elem() && !r.DoNotBase64 {
 		st.Type = "string"
-		// NOTE: ContentMediaType is not set here
+		// NOTE: ContentMediaType is set by the `contentMediaType` tag keyword
 		st.ContentEncoding = "base64"
 	} else {
 		st.Type = "array"
@@ -498,17 +1272,35 @@ func (r *Reflector) reflectMap(definitions Definitions, t reflect.Type, st *Sche
 
 	st.Type = "object"
 	if st.Description == "" {
-		st.Description = r.lookupComment(t, "")
+		r.typeComment(t, st)
+	}
+
+	if r.SetsAsArrays && t.Elem().Kind() == reflect.Struct && t.Elem().NumField() == 0 {
+		st.Type = "array"
+		st.Items = r.refOrReflectTypeToSchema(definitions, t.Key())
+		st.UniqueItems = true
+		return
 	}
 
 	switch t.Key().Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		st.PatternProperties = map[string]*Schema{
+			"^-?[0-9]+$": r.refOrReflectTypeToSchema(definitions, t.Elem()),
+		}
+		st.AdditionalProperties = FalseSchema
+		return
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		st.PatternProperties = map[string]*Schema{
 			"^[0-9]+$": r.refOrReflectTypeToSchema(definitions, t.Elem()),
 		}
 		st.AdditionalProperties = FalseSchema
 		return
 	}
+	// Raw JSON values may hold anything, no constraint is put on the values
+	if t.Elem() == rawMessageType {
+		st.AdditionalProperties = TrueSchema
+		return
+	}
 	if t.Elem().Kind() != reflect.Interface {
 		st.PatternProperties = map[string]*Schema{
 			".*": r.refOrReflectTypeToSchema(definitions, t.Elem()),
@@ -527,13 +1319,16 @@ func (r *Reflector) reflectStruct(definitions Definitions, t reflect.Type, s *Sc
 	case uriType: // uri RFC section 7.3.6
 		s.Type = "string"
 		s.Format = "uri"
+		if r.URLFormat != "" {
+			s.Format = r.URLFormat
+		}
 		return
 	}
 
 	r.addDefinition(definitions, t, s)
 	s.Type = "object"
 	s.Properties = orderedmap.New()
-	s.Description = r.lookupComment(t, "")
+	r.typeComment(t, s)
 	if r.AssignAnchor {
 		s.Anchor = t.Name()
 	}
@@ -551,10 +1346,13 @@ func (r *Reflector) reflectStruct(definitions Definitions, t reflect.Type, s *Sc
 	if !ignored {
 		r.reflectStructFields(s, definitions, t)
 	}
+	if r.SortRequired {
+		sort.Strings(s.Required)
+	}
 }
 
 func (r *Reflector) reflectStructFields(st *Schema, definitions Definitions, t reflect.Type) {
-	if t.Kind() == reflect.Ptr {
+	for t.Kind() == reflect.Ptr {
 		t = t.Elem()
 	}
 	if t.Kind() != reflect.Struct {
@@ -578,19 +1376,53 @@ func (r *Reflector) reflectStructFields(st *Schema, definitions Definitions, t r
 			}
 			return
 		}
+		if r.MaxProperties > 0 && len(st.Properties.Keys()) >= r.MaxProperties {
+			if _, exists := st.Properties.Get(name); !exists {
+				st.appendComment(fmt.Sprintf("properties truncated to the first %d", r.MaxProperties))
+				return
+			}
+		}
 
 		property := r.refOrReflectTypeToSchema(definitions, f.Type)
-		property.structKeywordsFromTags(f, st, name)
-
-		// 自定义映射tag处理
-		if r.TagMapper != nil {
-			for key, call := range r.TagMapper {
-				keyTag := f.Tag.Get(key)
-				if len(keyTag) >= 1 {
-					call(key, keyTag, property, st)
+		if !r.IgnoreCustomTags {
+			refType := ""
+			if r.NamedScalarsAsDefs && property.Ref != "" {
+				if def := definitions[strings.TrimPrefix(property.Ref, "#/$defs/")]; def != nil && isScalarType(def.Type) {
+					refType = def.Type
+				}
+			}
+			property.structKeywordsFromTags(f, st, name, r.schemaTagKey(), r.examplesSeparator(), refType)
+
+			// jsonschema_meta:"group=billing,icon=card" 写入 MetaData
+			for _, tag := range splitOnUnescapedCommas(f.Tag.Get(r.schemaTagKey() + "_meta")) {
+				if nameValue := strings.SplitN(tag, "=", 2); len(nameValue) == 2 {
+					property.AddMeta(nameValue[0], nameValue[1])
+				}
+			}
+
+			// 自定义映射tag处理
+			if r.TagMapper != nil {
+				for key, call := range r.TagMapper {
+					keyTag := f.Tag.Get(key)
+					if len(keyTag) >= 1 {
+						call(key, keyTag, property, st)
+					}
 				}
 			}
 		}
+		if r.tagErrors != nil && !r.IgnoreCustomTags {
+			r.checkTags(definitions, t, f, property)
+		}
+		if r.ImportValidateTags {
+			property.validateKeywords(f.Tag.Get("validate"))
+		}
+		if required && property.Default != nil && r.OnRequiredWithDefault != nil {
+			required = r.OnRequiredWithDefault(t, f, name)
+		}
+		if r.NonEmptyRequiredSlices && required && f.Type.Kind() == reflect.Slice &&
+			property.Type == "array" && property.MinItems == 0 {
+			property.MinItems = 1
+		}
 
 		if property.Description == "" {
 			property.Description = r.lookupComment(t, f.Name)
@@ -598,24 +1430,54 @@ func (r *Reflector) reflectStructFields(st *Schema, definitions Definitions, t r
 		if getFieldDocString != nil {
 			property.Description = getFieldDocString(f.Name)
 		}
+		if r.DescriptionResolver != nil && !r.IgnoreCustomTags {
+			if desc := r.resolveDescription(f); desc != "" {
+				property.Description = desc
+			}
+		}
 
-		if nullable {
-			property = &Schema{
-				OneOf: []*Schema{
-					property,
-					{
-						Type: "null",
-					},
-				},
+		if r.writeOnlyByName(f.Name) {
+			property.WriteOnly = true
+		}
+		if property.ReadOnly && property.WriteOnly {
+			// 同时只读与只写是矛盾的 StrictTags 时报错 否则保留 readOnly 并记录说明
+			if r.tagErrors != nil {
+				*r.tagErrors = append(*r.tagErrors, fmt.Errorf("%s.%s: both readOnly and writeOnly", t.Name(), f.Name))
 			}
+			property.WriteOnly = false
+			property.appendComment("writeOnly dropped, conflicts with readOnly")
 		}
 
-		// 判断自定义修改器
-		if r.Modifier != nil {
-			r.Modifier(property, f, st, t, name)
+		if r.AutoFormat && property.Type == "string" && property.Format == "" {
+			guesser := r.FormatGuesser
+			if guesser == nil {
+				guesser = DefaultFormatGuesser
+			}
+			property.Format = guesser(f)
 		}
 
-		st.Properties.Set(name, property)
+		// Before 2019-09 keywords next to $ref are ignored, keep them beside an allOf
+		if property.Ref != "" && !r.refSiblingsAllowed() {
+			property = wrapRefSiblings(property)
+		}
+
+		if nullable {
+			property = r.nullableSchema(property)
+		}
+		// set on the outermost schema, once any wrapping is done
+		if r.PreserveGoFieldName {
+			if property.Extras == nil {
+				property.Extras = map[string]interface{}{}
+			}
+			property.Extras["x-go-name"] = f.Name
+		}
+
+		// 判断自定义修改器
+		if r.Modifier != nil {
+			r.Modifier(property, f, st, t, name)
+		}
+
+		st.Properties.Set(name, property)
 		if required {
 			st.Required = appendUniqueString(st.Required, name)
 		}
@@ -634,6 +1496,43 @@ func (r *Reflector) reflectStructFields(st *Schema, definitions Definitions, t r
 	}
 }
 
+func (r *Reflector) writeOnlyByName(fieldName string) bool {
+	name := strings.ToLower(fieldName)
+	for _, sub := range r.WriteOnlyByName {
+		if sub != "" && strings.Contains(name, strings.ToLower(sub)) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultFormatGuesser infers a string format from common field naming
+// conventions, used by AutoFormat when no FormatGuesser is provided.
+func DefaultFormatGuesser(f reflect.StructField) string {
+	name := strings.ToLower(f.Name)
+	switch {
+	case name == "email" || strings.HasSuffix(name, "email"):
+		return "email"
+	case hasWordSuffix(f.Name, "url") || hasWordSuffix(f.Name, "uri"):
+		return "uri"
+	}
+	return ""
+}
+
+// hasWordSuffix reports whether the name ends with the word, matched case
+// insensitively, as a word of its own: the whole name, a camel-case word such
+// as `AvatarURL` or `AvatarUrl`, or after an underscore. `Curl` doesn't match.
+func hasWordSuffix(name, word string) bool {
+	if len(name) < len(word) || !strings.EqualFold(name[len(name)-len(word):], word) {
+		return false
+	}
+	if len(name) == len(word) {
+		return true
+	}
+	prev, first := rune(name[len(name)-len(word)-1]), rune(name[len(name)-len(word)])
+	return prev == '_' || unicode.IsUpper(first) && !unicode.IsUpper(prev)
+}
+
 func appendUniqueString(base []string, value string) []string {
 	for _, v := range base {
 		if v == value {
@@ -644,6 +1543,10 @@ func appendUniqueString(base []string, value string) []string {
 }
 
 func (r *Reflector) lookupComment(t reflect.Type, name string) string {
+	return r.truncateDescription(r.rawComment(t, name))
+}
+
+func (r *Reflector) rawComment(t reflect.Type, name string) string {
 	if r.CommentMap == nil {
 		return ""
 	}
@@ -656,15 +1559,158 @@ func (r *Reflector) lookupComment(t reflect.Type, name string) string {
 	return r.CommentMap[n]
 }
 
+// typeComment sets the description of a type's schema from its comment, split
+// into title and description when TitleFromComment is enabled.
+func (r *Reflector) typeComment(t reflect.Type, s *Schema) {
+	comment := r.rawComment(t, "")
+	if !r.TitleFromComment || s.Title != "" || comment == "" {
+		s.Description = r.truncateDescription(comment)
+		return
+	}
+	title, rest := firstSentence(comment)
+	s.Title = title
+	s.Description = r.truncateDescription(rest)
+}
+
+// firstSentence splits text after its first period followed by whitespace, or
+// at its first blank line, dropping the period from the returned sentence.
+func firstSentence(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	end := len(text)
+	if i := strings.Index(text, "\n\n"); i >= 0 {
+		end = i
+	}
+	for i := 0; i < end; i++ {
+		if text[i] == '.' && (i+1 == len(text) || unicode.IsSpace(rune(text[i+1]))) {
+			return text[:i], strings.TrimSpace(text[i+1:])
+		}
+	}
+	return strings.TrimSpace(text[:end]), strings.TrimSpace(text[end:])
+}
+
+// resolveDescription looks up the field's `desc_key=` with the DescriptionResolver.
+func (r *Reflector) resolveDescription(f reflect.StructField) string {
+	for _, tag := range splitOnUnescapedCommas(f.Tag.Get(r.schemaTagKey())) {
+		if key := strings.TrimPrefix(tag, "desc_key="); key != tag && key != "" {
+			return r.DescriptionResolver(key)
+		}
+	}
+	return ""
+}
+
+func (r *Reflector) truncateDescription(desc string) string {
+	if r.DescriptionMaxLength <= 0 {
+		return desc
+	}
+	runes := []rune(desc)
+	if len(runes) <= r.DescriptionMaxLength {
+		return desc
+	}
+	return strings.TrimSpace(string(runes[:r.DescriptionMaxLength])) + "..."
+}
+
 // addDefinition will append the provided schema. If needed, an ID and anchor will also be added.
 func (r *Reflector) addDefinition(definitions Definitions, t reflect.Type, s *Schema) {
-	name := r.typeName(t)
+	r.addNamedDefinition(definitions, r.typeName(t), t, s)
+}
+
+// addNamedDefinition adds the schema for the type under the given name.
+func (r *Reflector) addNamedDefinition(definitions Definitions, name string, t reflect.Type, s *Schema) {
 	if name == "" {
 		return
 	}
+	if r.TitleFromName && s.Title == "" {
+		s.Title = humanizeName(name)
+	}
+	if r.AnnotateProvenance {
+		s.appendComment(fullyQualifiedTypeName(t))
+	}
 	definitions[name] = s
 }
 
+// nullableSchema allows null in addition to the values matched by the schema.
+func (r *Reflector) nullableSchema(s *Schema) *Schema {
+	if r.OpenAPINullable {
+		if s.Ref != "" {
+			s = &Schema{AllOf: []*Schema{s}}
+		}
+		if s.Extras == nil {
+			s.Extras = map[string]interface{}{}
+		}
+		s.Extras["nullable"] = true
+		return s
+	}
+	return &Schema{
+		OneOf: []*Schema{
+			s,
+			{
+				Type: "null",
+			},
+		},
+	}
+}
+
+// version provides the `$schema` of root schemas, empty with OmitVersion.
+func (r *Reflector) version() string {
+	if r.OmitVersion {
+		return ""
+	}
+	return r.schemaVersion()
+}
+
+// schemaVersion provides the JSON Schema version targeted by the reflector,
+// captured by forCall when reflection starts.
+func (r *Reflector) schemaVersion() string {
+	if r.SchemaVersion != "" {
+		return r.SchemaVersion
+	}
+	return Version
+}
+
+// refSiblingsAllowed reports whether the targeted JSON Schema version, draft
+// 2019-09 and later, applies the keywords found next to a `$ref`.
+func (r *Reflector) refSiblingsAllowed() bool {
+	for _, draft := range []string{"draft-04", "draft-06", "draft-07"} {
+		if strings.Contains(r.schemaVersion(), draft) {
+			return false
+		}
+	}
+	return true
+}
+
+// wrapRefSiblings moves the `$ref` of a schema holding other keywords into an
+// `allOf`, so the keywords still apply under drafts ignoring `$ref` siblings.
+func wrapRefSiblings(s *Schema) *Schema {
+	ref := &Schema{Ref: s.Ref}
+	if reflect.DeepEqual(s, ref) {
+		return s
+	}
+	wrapped := *s
+	wrapped.Ref = ""
+	wrapped.AllOf = append([]*Schema{ref}, s.AllOf...)
+	return &wrapped
+}
+
+// definitionDone calls the OnDefinition hook once the schema added to the
+// definitions for the type has been fully populated.
+func (r *Reflector) definitionDone(definitions Definitions, t reflect.Type, s *Schema) {
+	if r.OnDefinition == nil {
+		return
+	}
+	r.namedDefinitionDone(definitions, r.typeName(t), t, s)
+}
+
+// namedDefinitionDone calls the OnDefinition hook for a schema added under the
+// given name.
+func (r *Reflector) namedDefinitionDone(definitions Definitions, name string, t reflect.Type, s *Schema) {
+	if r.OnDefinition == nil {
+		return
+	}
+	if name != "" && definitions[name] == s {
+		r.OnDefinition(name, s, t)
+	}
+}
+
 // refDefinition will provide a schema with a reference to an existing definition.
 func (r *Reflector) refDefinition(definitions Definitions, t reflect.Type) *Schema {
 	if r.DoNotReference {
@@ -684,7 +1730,7 @@ func (r *Reflector) refDefinition(definitions Definitions, t reflect.Type) *Sche
 
 func (r *Reflector) lookupID(t reflect.Type) ID {
 	if r.Lookup != nil {
-		if t.Kind() == reflect.Ptr {
+		for t.Kind() == reflect.Ptr {
 			t = t.Elem()
 		}
 		return r.Lookup(t)
@@ -693,11 +1739,32 @@ func (r *Reflector) lookupID(t reflect.Type) ID {
 	return EmptyID
 }
 
-func (t *Schema) structKeywordsFromTags(f reflect.StructField, parent *Schema, propertyName string) {
-	t.Description = f.Tag.Get("jsonschema_description")
+func (t *Schema) structKeywordsFromTags(f reflect.StructField, parent *Schema, propertyName string, tagKey string, examplesSep string, refType string) {
+	t.Description = f.Tag.Get(tagKey + "_description")
+
+	tags := expandExamplesTag(splitOnUnescapedCommas(f.Tag.Get(tagKey)), examplesSep)
+
+	// keywords of a referenced scalar are parsed for its type and kept beside the $ref
+	if refType != "" && t.Type == "" {
+		t.Type = refType
+		defer func() {
+			if t.Type == refType && !typeFromJSONSchemaTags(tags) {
+				t.Type = ""
+			}
+		}()
+	}
 
-	tags := splitOnUnescapedCommas(f.Tag.Get("jsonschema"))
-	t.genericKeywords(tags, parent, propertyName)
+	t.genericKeywords(tags, parent, f, propertyName)
+
+	for _, tag := range tags {
+		if tag == "discriminator" {
+			// OpenAPI 风格 记录在父级上 前端可根据该字段选择组件
+			if parent.Extras == nil {
+				parent.Extras = map[string]interface{}{}
+			}
+			parent.Extras["discriminator"] = map[string]interface{}{"propertyName": propertyName}
+		}
+	}
 
 	switch t.Type {
 	case "string":
@@ -708,31 +1775,166 @@ func (t *Schema) structKeywordsFromTags(f reflect.StructField, parent *Schema, p
 		t.numbericKeywords(tags)
 	case "array":
 		t.arrayKeywords(tags)
+	case "object":
+		t.objectKeywords(tags)
 	case "boolean":
 		t.booleanKeywords(tags)
 	}
-	extras := strings.Split(f.Tag.Get("jsonschema_extras"), ",")
+	extras := strings.Split(f.Tag.Get(tagKey+"_extras"), ",")
 	t.extraKeywords(extras)
 
 }
 
+// referencedDefinitions returns the definitions transitively referenced from
+// the schema, such as recursive types reflected with DoNotReference.
+func referencedDefinitions(s *Schema, definitions Definitions) Definitions {
+	var res Definitions
+	var walk func(t *Schema)
+	walk = func(t *Schema) {
+		if name := strings.TrimPrefix(t.Ref, "#/$defs/"); name != t.Ref {
+			if _, ok := res[name]; !ok && definitions[name] != nil {
+				if res == nil {
+					res = Definitions{}
+				}
+				res[name] = definitions[name]
+				walk(definitions[name])
+			}
+		}
+		for _, sub := range t.subSchemas() {
+			walk(sub)
+		}
+	}
+	walk(s)
+	return res
+}
+
+// contentAddressDefinitions renames the definitions after a hash of their
+// content, updating references until names no longer change. As a name is
+// part of the content of the definitions referencing it, each round settles
+// one more level, bounded by the number of definitions for recursive ones.
+func contentAddressDefinitions(root *Schema, definitions Definitions) Definitions {
+	for round := 0; round <= len(definitions); round++ {
+		renamed := make(map[string]string, len(definitions))
+		changed := false
+		for name, def := range definitions {
+			data, _ := json.Marshal(def)
+			sum := sha256.Sum256(data)
+			renamed[name] = fmt.Sprintf("def_%x", sum[:8])
+			changed = changed || renamed[name] != name
+		}
+		if !changed {
+			break
+		}
+
+		// names may be swapped between rounds, so every schema is renamed once
+		visited := map[*Schema]bool{}
+		var walk func(t *Schema)
+		walk = func(t *Schema) {
+			if visited[t] {
+				return
+			}
+			visited[t] = true
+			if name := strings.TrimPrefix(t.Ref, "#/$defs/"); name != t.Ref && renamed[name] != "" {
+				t.Ref = "#/$defs/" + renamed[name]
+			}
+			for _, sub := range t.subSchemas() {
+				walk(sub)
+			}
+		}
+		walk(root)
+		next := make(Definitions, len(definitions))
+		for name, def := range definitions {
+			walk(def)
+			next[renamed[name]] = def
+		}
+		definitions = next
+	}
+	return definitions
+}
+
+// appendComment adds a note to the `$comment` of the schema, after any comment
+// already set by a tag or another option.
+func (t *Schema) appendComment(comment string) {
+	switch {
+	case t.Comments == "":
+		t.Comments = comment
+	case !strings.Contains(t.Comments, comment):
+		t.Comments += "; " + comment
+	}
+}
+
+// limitEnums walks the schema removing any enum larger than MaxEnumValues.
+func (r *Reflector) limitEnums(t *Schema) {
+	if t == nil {
+		return
+	}
+	if len(t.Enum) > r.MaxEnumValues {
+		if !r.DropOversizedEnums {
+			t.appendComment(fmt.Sprintf("enum of %d values omitted", len(t.Enum)))
+		}
+		t.Enum = nil
+	}
+	for _, s := range t.subSchemas() {
+		r.limitEnums(s)
+	}
+}
+
+// enumExamples walks the schema copying enum values into empty examples.
+func enumExamples(t *Schema) {
+	if t == nil {
+		return
+	}
+	if len(t.Enum) > 0 && len(t.Examples) == 0 {
+		t.Examples = append([]interface{}(nil), t.Enum...)
+	}
+	for _, s := range t.subSchemas() {
+		enumExamples(s)
+	}
+}
+
 // read struct tags for generic keyworks
-func (t *Schema) genericKeywords(tags []string, parent *Schema, propertyName string) {
+func (t *Schema) genericKeywords(tags []string, parent *Schema, f reflect.StructField, propertyName string) {
 	for _, tag := range tags {
-		nameValue := strings.Split(tag, "=")
+		nameValue := strings.SplitN(tag, "=", 2)
 		if len(nameValue) == 2 {
 			name, val := nameValue[0], nameValue[1]
 			switch name {
 			case "title":
-				t.Title = val
+				t.Title = interpolateTitle(val, f)
 			case "description":
 				t.Description = val
+			case "comment":
+				t.Comments = val
+			case "readOnly":
+				i, _ := strconv.ParseBool(val)
+				t.ReadOnly = i
+			case "writeOnly":
+				i, _ := strconv.ParseBool(val)
+				t.WriteOnly = i
 			case "widget":
 				t.Widget = val
 			case "type":
-				t.Type = val
+				// type=string;integer 会生成 "type":["string","integer"]
+				if types := strings.Split(val, ";"); len(types) > 1 {
+					t.Type = ""
+					t.Types = types
+				} else {
+					t.Type = val
+				}
 			case "anchor":
+				// $anchor 在同一个文档中必须唯一 重复的名称会导致引用时无法确定目标
 				t.Anchor = val
+			case "anchor_ref":
+				// 通过 $anchor 引用同一文档中其他字段的定义
+				t.Ref = "#" + val
+			case "additionalProperties":
+				if b, err := strconv.ParseBool(val); err == nil {
+					if b {
+						t.AdditionalProperties = TrueSchema
+					} else {
+						t.AdditionalProperties = FalseSchema
+					}
+				}
 			case "oneof_required":
 				var typeFound *Schema
 				for i := range parent.OneOf {
@@ -763,6 +1965,19 @@ func (t *Schema) genericKeywords(tags []string, parent *Schema, propertyName str
 					parent.AnyOf = append(parent.AnyOf, typeFound)
 				}
 				typeFound.Required = append(typeFound.Required, propertyName)
+			case "union":
+				// 同一组的字段互斥 通过 allOf 中以组名为标题的 oneOf 实现 每个分支只要求一个字段
+				var group *Schema
+				for i := range parent.AllOf {
+					if parent.AllOf[i].unionGroup == nameValue[1] {
+						group = parent.AllOf[i]
+					}
+				}
+				if group == nil {
+					group = &Schema{Title: nameValue[1], unionGroup: nameValue[1]}
+					parent.AllOf = append(parent.AllOf, group)
+				}
+				group.OneOf = append(group.OneOf, &Schema{Required: []string{propertyName}})
 			case "oneof_type":
 				if t.OneOf == nil {
 					t.OneOf = make([]*Schema, 0, 1)
@@ -785,6 +2000,21 @@ func (t *Schema) genericKeywords(tags []string, parent *Schema, propertyName str
 						Type: ty,
 					})
 				}
+			case "not_const":
+				// 仅支持简单的 not:{const:x} 复杂的取反请使用 SetNot
+				switch t.Type {
+				case "integer":
+					i, _ := strconv.Atoi(val)
+					t.SetNot(&Schema{Const: i})
+				case "number":
+					f, _ := strconv.ParseFloat(val, 64)
+					t.SetNot(&Schema{Const: f})
+				case "boolean":
+					b, _ := strconv.ParseBool(val)
+					t.SetNot(&Schema{Const: b})
+				default:
+					t.SetNot(&Schema{Const: val})
+				}
 			case "enum":
 				switch t.Type {
 				case "string":
@@ -796,6 +2026,149 @@ func (t *Schema) genericKeywords(tags []string, parent *Schema, propertyName str
 					f, _ := strconv.ParseFloat(val, 64)
 					t.Enum = append(t.Enum, f)
 				}
+			case "enum_titled":
+				// enum_titled=a:Apple;b:Banana 生成带标题的 oneOf const 便于前端生成下拉选项
+				for _, item := range strings.Split(val, ";") {
+					constTitle := strings.SplitN(item, ":", 2)
+					option := &Schema{}
+					switch t.Type {
+					case "string":
+						option.Const = constTitle[0]
+					case "integer":
+						i, err := strconv.Atoi(constTitle[0])
+						if err != nil {
+							continue
+						}
+						option.Const = i
+					case "number":
+						f, err := strconv.ParseFloat(constTitle[0], 64)
+						if err != nil {
+							continue
+						}
+						option.Const = f
+					default:
+						continue
+					}
+					if len(constTitle) == 2 {
+						option.Title = constTitle[1]
+					}
+					t.OneOf = append(t.OneOf, option)
+				}
+			case "enum_json":
+				// enum 按照字段类型解析 enum_json 则将值按JSON解析 保留值本身的类型
+				// 用于 true/false null 或者 "123" 这种看起来像数字的字符串
+				var v interface{}
+				if err := json.Unmarshal([]byte(val), &v); err == nil {
+					t.Enum = append(t.Enum, v)
+				}
+			}
+		}
+	}
+}
+
+// interpolateTitle expands `$field` into the humanized field name and `$type`
+// into the name of the field's type.
+func interpolateTitle(title string, f reflect.StructField) string {
+	if !strings.Contains(title, "$") {
+		return title
+	}
+	ft := f.Type
+	for ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	typeName := ft.Name()
+	if typeName == "" {
+		typeName = ft.String()
+	}
+	return strings.NewReplacer("$field", humanizeName(f.Name), "$type", typeName).Replace(title)
+}
+
+// validateFormats maps go-playground/validator rules onto string formats.
+var validateFormats = map[string]string{
+	"email":    "email",
+	"url":      "uri",
+	"uri":      "uri",
+	"uuid":     "uuid",
+	"hostname": "hostname",
+	"ipv4":     "ipv4",
+	"ipv6":     "ipv6",
+}
+
+// read go-playground/validator rules from the validate tag
+func (t *Schema) validateKeywords(tag string) {
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "dive" {
+			return
+		}
+		nameValue := strings.SplitN(rule, "=", 2)
+		name := nameValue[0]
+		if len(nameValue) != 2 {
+			if format, ok := validateFormats[name]; ok && t.Type == "string" {
+				t.Format = format
+			}
+			continue
+		}
+		val := nameValue[1]
+		if name == "oneof" {
+			for _, v := range strings.Fields(val) {
+				switch t.Type {
+				case "string":
+					t.Enum = append(t.Enum, v)
+				case "integer":
+					if i, err := strconv.Atoi(v); err == nil {
+						t.Enum = append(t.Enum, i)
+					}
+				case "number":
+					if f, err := strconv.ParseFloat(v, 64); err == nil {
+						t.Enum = append(t.Enum, f)
+					}
+				}
+			}
+			continue
+		}
+		i, err := strconv.Atoi(val)
+		if err != nil {
+			continue
+		}
+		isMin := name == "min" || name == "len" || name == "gte"
+		isMax := name == "max" || name == "len" || name == "lte"
+		if !isMin && !isMax {
+			continue
+		}
+		switch t.Type {
+		case "string":
+			if name == "gte" || name == "lte" {
+				continue
+			}
+			if isMin {
+				t.MinLength = i
+			}
+			if isMax {
+				t.MaxLength = i
+			}
+		case "integer", "number":
+			if name == "len" {
+				continue
+			}
+			if isMin {
+				t.Minimum = i
+			}
+			if isMax {
+				t.Maximum = i
+			}
+		case "array":
+			if isMin {
+				t.MinItems = i
+			}
+			if isMax {
+				t.MaxItems = i
+			}
+		case "object":
+			if isMin {
+				t.MinProperties = i
+			}
+			if isMax {
+				t.MaxProperties = i
 			}
 		}
 	}
@@ -804,7 +2177,7 @@ func (t *Schema) genericKeywords(tags []string, parent *Schema, propertyName str
 // read struct tags for boolean type keyworks
 func (t *Schema) booleanKeywords(tags []string) {
 	for _, tag := range tags {
-		nameValue := strings.Split(tag, "=")
+		nameValue := strings.SplitN(tag, "=", 2)
 		if len(nameValue) != 2 {
 			continue
 		}
@@ -821,8 +2194,17 @@ func (t *Schema) booleanKeywords(tags []string) {
 
 // read struct tags for string type keyworks
 func (t *Schema) stringKeywords(tags []string) {
+	// bytes=hex switches []byte from base64 before minBytes and maxBytes apply
+	hex := false
 	for _, tag := range tags {
-		nameValue := strings.Split(tag, "=")
+		if tag == "bytes=hex" && t.ContentEncoding == "base64" {
+			t.ContentEncoding = ""
+			t.Pattern = "^[0-9a-fA-F]*$"
+			hex = true
+		}
+	}
+	for _, tag := range tags {
+		nameValue := strings.SplitN(tag, "=", 2)
 		if len(nameValue) == 2 {
 			name, val := nameValue[0], nameValue[1]
 			switch name {
@@ -832,20 +2214,28 @@ func (t *Schema) stringKeywords(tags []string) {
 			case "maxLength":
 				i, _ := strconv.Atoi(val)
 				t.MaxLength = i
+			case "minBytes":
+				i, _ := strconv.Atoi(val)
+				if t.ContentEncoding == "base64" {
+					t.MinLength = base64Len(i)
+				} else if hex {
+					t.MinLength = 2 * i
+				}
+			case "maxBytes":
+				i, _ := strconv.Atoi(val)
+				if t.ContentEncoding == "base64" {
+					t.MaxLength = base64Len(i)
+				} else if hex {
+					t.MaxLength = 2 * i
+				}
+			case "contentMediaType":
+				t.ContentMediaType = val
 			case "pattern":
 				t.Pattern = val
 			case "format":
-				switch val {
-				case "date-time", "email", "hostname", "ipv4", "ipv6", "uri", "uuid":
+				if stringFormats[val] {
 					t.Format = val
-					break
 				}
-			case "readOnly":
-				i, _ := strconv.ParseBool(val)
-				t.ReadOnly = i
-			case "writeOnly":
-				i, _ := strconv.ParseBool(val)
-				t.WriteOnly = i
 			case "default":
 				t.Default = val
 			case "example":
@@ -855,10 +2245,15 @@ func (t *Schema) stringKeywords(tags []string) {
 	}
 }
 
+// base64Len returns the length of n bytes once encoded as padded base64.
+func base64Len(n int) int {
+	return (n + 2) / 3 * 4
+}
+
 // read struct tags for numberic type keyworks
 func (t *Schema) numbericKeywords(tags []string) {
 	for _, tag := range tags {
-		nameValue := strings.Split(tag, "=")
+		nameValue := strings.SplitN(tag, "=", 2)
 		if len(nameValue) == 2 {
 			name, val := nameValue[0], nameValue[1]
 			switch name {
@@ -890,26 +2285,28 @@ func (t *Schema) numbericKeywords(tags []string) {
 }
 
 // read struct tags for object type keyworks
-// func (t *Type) objectKeywords(tags []string) {
-//     for _, tag := range tags{
-//         nameValue := strings.Split(tag, "=")
-//         name, val := nameValue[0], nameValue[1]
-//         switch name{
-//             case "dependencies":
-//                 t.Dependencies = val
-//                 break;
-//             case "patternProperties":
-//                 t.PatternProperties = val
-//                 break;
-//         }
-//     }
-// }
+func (t *Schema) objectKeywords(tags []string) {
+	for _, tag := range tags {
+		nameValue := strings.SplitN(tag, "=", 2)
+		if len(nameValue) == 2 {
+			name, val := nameValue[0], nameValue[1]
+			switch name {
+			case "minProperties":
+				i, _ := strconv.Atoi(val)
+				t.MinProperties = i
+			case "maxProperties":
+				i, _ := strconv.Atoi(val)
+				t.MaxProperties = i
+			}
+		}
+	}
+}
 
 // read struct tags for array type keyworks
 func (t *Schema) arrayKeywords(tags []string) {
 	var defaultValues []interface{}
 	for _, tag := range tags {
-		nameValue := strings.Split(tag, "=")
+		nameValue := strings.SplitN(tag, "=", 2)
 		if len(nameValue) == 2 {
 			name, val := nameValue[0], nameValue[1]
 			switch name {
@@ -999,6 +2396,31 @@ func requiredFromJSONTags(tags []string) bool {
 	return true
 }
 
+// emittedDespiteOmitempty reports if encoding/json always emits the field even
+// though it is tagged with `omitempty`, which has no effect on struct values.
+func emittedDespiteOmitempty(tags []string, t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || ignoredByJSONTags(tags) {
+		return false
+	}
+	for _, tag := range tags[1:] {
+		if tag == "omitzero" {
+			return false
+		}
+	}
+	return true
+}
+
+// conditionallyRequiredFromJSONSchemaTags reports if the field is required
+// only by some branches, through `oneof_required` or `anyof_required`.
+func conditionallyRequiredFromJSONSchemaTags(tags []string) bool {
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, "oneof_required=") || strings.HasPrefix(tag, "anyof_required=") {
+			return true
+		}
+	}
+	return false
+}
+
 func requiredFromJSONSchemaTags(tags []string) bool {
 	if ignoredByJSONSchemaTags(tags) {
 		return false
@@ -1011,6 +2433,33 @@ func requiredFromJSONSchemaTags(tags []string) bool {
 	return false
 }
 
+func requiredFromValidateTag(tag string) bool {
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+func typeFromJSONSchemaTags(tags []string) bool {
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, "type=") {
+			return true
+		}
+	}
+	return false
+}
+
+func unionFromJSONSchemaTags(tags []string) bool {
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, "union=") {
+			return true
+		}
+	}
+	return false
+}
+
 func nullableFromJSONSchemaTags(tags []string) bool {
 	if ignoredByJSONSchemaTags(tags) {
 		return false
@@ -1023,6 +2472,39 @@ func nullableFromJSONSchemaTags(tags []string) bool {
 	return false
 }
 
+// isSyncType reports if the type, or the type it points to, belongs to the
+// `sync` or `sync/atomic` packages. Their internals are never meant to be
+// serialized, so fields of these types are skipped.
+func isSyncType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.PkgPath() {
+	case "sync", "sync/atomic":
+		return true
+	}
+	return false
+}
+
+// inProfile 判断字段是否属于当前的 Profile 未设置 profiles 标签的字段始终生成
+func (r *Reflector) inProfile(f reflect.StructField) bool {
+	if r.Profile == "" {
+		return true
+	}
+	for _, tag := range splitOnUnescapedCommas(f.Tag.Get(r.schemaTagKey())) {
+		nameValue := strings.SplitN(tag, "=", 2)
+		if len(nameValue) == 2 && nameValue[0] == "profiles" {
+			for _, profile := range strings.Split(nameValue[1], ";") {
+				if profile == r.Profile {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return true
+}
+
 func ignoredByJSONTags(tags []string) bool {
 	return tags[0] == "-"
 }
@@ -1037,25 +2519,48 @@ func (r *Reflector) reflectFieldName(f reflect.StructField) (string, bool, bool,
 	if r.Intercept != nil && !r.Intercept(f) {
 		return "", false, false, false
 	}
+	if !r.inProfile(f) {
+		return "", false, false, false
+	}
+	if isSyncType(f.Type) {
+		return "", false, false, false
+	}
 
 	jsonTagString, _ := f.Tag.Lookup("json")
 	jsonTags := strings.Split(jsonTagString, ",")
 
-	if ignoredByJSONTags(jsonTags) {
-		return "", false, false, false
+	// json:"-" fields are never serialized, but may still be documented
+	jsonDash := ignoredByJSONTags(jsonTags)
+	if jsonDash {
+		if !r.IncludeJSONDashWithSchemaTag || f.Tag.Get(r.schemaTagKey()) == "" {
+			return "", false, false, false
+		}
+		jsonTags = []string{""}
 	}
 
-	schemaTags := strings.Split(f.Tag.Get("jsonschema"), ",")
+	schemaTags := strings.Split(f.Tag.Get(r.schemaTagKey()), ",")
 	if ignoredByJSONSchemaTags(schemaTags) {
 		return "", false, false, false
 	}
 
 	required := requiredFromJSONTags(jsonTags)
-	if r.RequiredFromJSONSchemaTags {
-		required = requiredFromJSONSchemaTags(schemaTags)
+	if !required && emittedDespiteOmitempty(jsonTags, f.Type) && !conditionallyRequiredFromJSONSchemaTags(schemaTags) {
+		required = true
+	}
+	if r.RequiredFromJSONSchemaTags || r.RequiredFromValidateTag {
+		required = r.RequiredFromJSONSchemaTags && requiredFromJSONSchemaTags(schemaTags) ||
+			r.RequiredFromValidateTag && requiredFromValidateTag(f.Tag.Get("validate"))
+	}
+	if jsonDash {
+		required = false
+	}
+
+	if !r.IgnoreCustomTags && unionFromJSONSchemaTags(schemaTags) {
+		// union members are required by their oneOf branch instead
+		required = false
 	}
 
-	nullable := nullableFromJSONSchemaTags(schemaTags)
+	nullable := !r.IgnoreCustomTags && nullableFromJSONSchemaTags(schemaTags)
 
 	if f.Anonymous && jsonTags[0] == "" {
 		// As per JSON Marshal rules, anonymous structs are inherited
@@ -1067,6 +2572,12 @@ func (r *Reflector) reflectFieldName(f reflect.StructField) (string, bool, bool,
 		if f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct {
 			return "", true, false, false
 		}
+
+		// Embedded interfaces only describe behaviour, they contribute no properties
+		// and are never required. Use a json tag to expose them as a named property.
+		if f.Type.Kind() == reflect.Interface {
+			return "", false, false, false
+		}
 	}
 
 	// Try to determine the name from the different combos
@@ -1077,6 +2588,8 @@ func (r *Reflector) reflectFieldName(f reflect.StructField) (string, bool, bool,
 	if !f.Anonymous && f.PkgPath != "" {
 		// field not anonymous and not export has no export name
 		name = ""
+	} else if r.KeyNamerFull != nil {
+		name = r.KeyNamerFull(f, name)
 	} else if r.KeyNamer != nil {
 		name = r.KeyNamer(name)
 	}
@@ -1096,10 +2609,48 @@ func (t *Schema) UnmarshalJSON(data []byte) error {
 	type Schema_ Schema
 	aux := &struct {
 		*Schema_
+		Type interface{} `json:"type,omitempty"`
 	}{
 		Schema_: (*Schema_)(t),
 	}
-	return json.Unmarshal(data, aux)
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+	switch typ := aux.Type.(type) {
+	case string:
+		t.Type = typ
+		t.Types = nil
+	case []interface{}:
+		types := make([]string, 0, len(typ))
+		for _, v := range typ {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		t.Type = ""
+		t.Types = types
+	}
+	return nil
+}
+
+// marshalExtras provides the keywords written after the struct fields: the
+// Extras along with the array form of `type`. Only one `type` is ever written,
+// Types taking precedence over Type, which takes precedence over Extras.
+func (t *Schema) marshalExtras() map[string]interface{} {
+	_, clash := t.Extras["type"]
+	clash = clash && (t.Type != "" || len(t.Types) > 0)
+	if len(t.Types) == 0 && !clash {
+		return t.Extras
+	}
+	extras := make(map[string]interface{}, len(t.Extras)+1)
+	for k, v := range t.Extras {
+		extras[k] = v
+	}
+	delete(extras, "type")
+	if len(t.Types) > 0 {
+		extras["type"] = t.Types
+	}
+	return extras
 }
 
 func (t *Schema) MarshalJSON() ([]byte, error) {
@@ -1111,18 +2662,25 @@ func (t *Schema) MarshalJSON() ([]byte, error) {
 		}
 	}
 	if reflect.DeepEqual(&Schema{}, t) {
-		// Don't bother returning empty schemas
+		// Don't bother returning empty schemas, unless asked to with KeepEmpty
 		return []byte("true"), nil
 	}
 	type Schema_ Schema
-	b, err := json.Marshal((*Schema_)(t))
+	plain := (*Schema_)(t)
+	if len(t.Types) > 0 && t.Type != "" {
+		c := *plain
+		c.Type = ""
+		plain = &c
+	}
+	b, err := json.Marshal(plain)
 	if err != nil {
 		return nil, err
 	}
-	if t.Extras == nil || len(t.Extras) == 0 {
+	extras := t.marshalExtras()
+	if len(extras) == 0 {
 		return b, nil
 	}
-	m, err := json.Marshal(t.Extras)
+	m, err := json.Marshal(extras)
 	if err != nil {
 		return nil, err
 	}
@@ -1133,15 +2691,122 @@ func (t *Schema) MarshalJSON() ([]byte, error) {
 	return append(b, m[1:]...), nil
 }
 
+func (r *Reflector) examplesSeparator() string {
+	if r.ExamplesSeparator == "" {
+		return "|"
+	}
+	return r.ExamplesSeparator
+}
+
+func (r *Reflector) schemaTagKey() string {
+	if r.SchemaTagKey != "" {
+		return r.SchemaTagKey
+	}
+	return "jsonschema"
+}
+
+// anonStructName names an anonymous struct after a digest of its shape. The
+// shape's string form only qualifies types by package name, so two shapes may
+// still share one; the later one seen gets a suffix.
+func (r *Reflector) anonStructName(t reflect.Type) string {
+	sum := sha256.Sum256([]byte(t.String()))
+	return r.claimName(fmt.Sprintf("Anon%x", sum[:8]), t, nil)
+}
+
+// claimName reserves a name made up by the reflector for the type. A suffix is
+// added while the name is claimed by another type, or is already used in the
+// definitions by a type reflected earlier.
+func (r *Reflector) claimName(base string, t reflect.Type, definitions Definitions) string {
+	if r.claimedNames == nil {
+		return base
+	}
+	name := base
+	for i := 2; ; i++ {
+		owner, claimed := r.claimedNames[name]
+		if claimed && owner == t {
+			return name
+		}
+		if !claimed && definitions[name] == nil && !r.inProgress[name] {
+			r.claimedNames[name] = t
+			return name
+		}
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+}
+
 func (r *Reflector) typeName(t reflect.Type) string {
+	name := r.declaredTypeName(t)
+	if owner, ok := r.claimedNames[name]; ok && owner != t {
+		// the name was made up for another type first
+		return r.claimName(name, t, nil)
+	}
+	return name
+}
+
+func (r *Reflector) declaredTypeName(t reflect.Type) string {
 	if r.Namer != nil {
 		if name := r.Namer(t); name != "" {
 			return name
 		}
 	}
+	if r.AnonymousStructDefs && t.Kind() == reflect.Struct && t.Name() == "" {
+		return r.anonStructName(t)
+	}
+	if name := t.Name(); strings.Contains(name, "[") {
+		// Instantiated generic types carry fully qualified type arguments, such as
+		// `Page[github.com/org/pkg.User]`, which aren't usable in a JSON pointer.
+		// The brackets aren't allowed in a URI fragment either, so
+		// `Page[*pkg.User]` becomes `Page(*User)`.
+		return typeArgBrackets.Replace(typeArgQualifier.ReplaceAllString(name, ""))
+	}
 	return t.Name()
 }
 
+// typeArgQualifier matches the package qualifiers of the type arguments in a
+// generic type name.
+var typeArgQualifier = regexp.MustCompile(`(?:[\w.-]+/)*[\w-]+\.`)
+
+// typeArgBrackets swaps the brackets of a generic type name for parentheses,
+// which may appear unescaped in a `$ref` fragment.
+var typeArgBrackets = strings.NewReplacer("[", "(", "]", ")")
+
+// expandExamplesTag replaces an `examples=a|b` keyword with one `example=`
+// keyword per value, so they are parsed according to the property type.
+func expandExamplesTag(tags []string, sep string) []string {
+	ret := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, "examples=") {
+			ret = append(ret, tag)
+			continue
+		}
+		for _, val := range splitOnUnescaped(strings.TrimPrefix(tag, "examples="), sep) {
+			ret = append(ret, "example="+val)
+		}
+	}
+	return ret
+}
+
+// splitOnUnescaped splits the string on each separator not preceded by a backslash.
+func splitOnUnescaped(s, sep string) []string {
+	var ret []string
+	var cur strings.Builder
+	for {
+		i := strings.Index(s, sep)
+		if i < 0 {
+			cur.WriteString(s)
+			return append(ret, cur.String())
+		}
+		if i > 0 && s[i-1] == '\\' {
+			cur.WriteString(s[:i-1] + sep)
+		} else {
+			cur.WriteString(s[:i])
+			ret = append(ret, cur.String())
+			cur.Reset()
+		}
+		s = s[i+len(sep):]
+	}
+}
+
 // Split on commas that are not preceded by `\`.
 // This way, we prevent splitting regexes
 func splitOnUnescapedCommas(tagString string) []string {
@@ -1168,6 +2833,9 @@ func splitOnUnescapedCommas(tagString string) []string {
 }
 
 func fullyQualifiedTypeName(t reflect.Type) string {
+	if t.Name() == "" {
+		return t.String()
+	}
 	return t.PkgPath() + "." + t.Name()
 }
 
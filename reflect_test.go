@@ -6,11 +6,16 @@ import (
 	"fmt"
 	"github.com/23233/jsonschema/examples"
 	"io/ioutil"
+	"math/big"
 	"net"
+	"net/netip"
 	"net/url"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -552,3 +557,2656 @@ func TestArrayFormat(t *testing.T) {
 	pt := p.Items.Format
 	require.Equal(t, pt, "uri")
 }
+
+func TestCommentTag(t *testing.T) {
+	type Commented struct {
+		Name string `json:"name" jsonschema:"comment=for schema authors only,description=shown to users"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Commented{})
+	i, found := schema.Properties.Get("name")
+	require.True(t, found)
+
+	p := i.(*Schema)
+	assert.Equal(t, "for schema authors only", p.Comments)
+	assert.Equal(t, "shown to users", p.Description)
+}
+
+func TestAdditionalPropertiesTag(t *testing.T) {
+	type Nested struct {
+		Value string `json:"value"`
+	}
+	type Parent struct {
+		Open   Nested `json:"open" jsonschema:"additionalProperties=true"`
+		Strict Nested `json:"strict" jsonschema:"additionalProperties=false"`
+		Plain  Nested `json:"plain"`
+	}
+
+	r := &Reflector{DoNotReference: true, AllowAdditionalProperties: true}
+	schema := r.Reflect(&Parent{})
+
+	i, found := schema.Properties.Get("open")
+	require.True(t, found)
+	assert.Equal(t, TrueSchema, i.(*Schema).AdditionalProperties)
+
+	i, found = schema.Properties.Get("strict")
+	require.True(t, found)
+	assert.Equal(t, FalseSchema, i.(*Schema).AdditionalProperties)
+
+	i, found = schema.Properties.Get("plain")
+	require.True(t, found)
+	assert.Nil(t, i.(*Schema).AdditionalProperties)
+}
+
+func TestJSONNumber(t *testing.T) {
+	type Amounts struct {
+		Amount json.Number `json:"amount"`
+		Count  json.Number `json:"count" jsonschema:"type=integer,minimum=1"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Amounts{})
+
+	i, found := schema.Properties.Get("amount")
+	require.True(t, found)
+	assert.Equal(t, "number", i.(*Schema).Type)
+
+	i, found = schema.Properties.Get("count")
+	require.True(t, found)
+	assert.Equal(t, "integer", i.(*Schema).Type)
+	assert.Equal(t, 1, i.(*Schema).Minimum)
+}
+
+func TestReflectSlice(t *testing.T) {
+	r := new(Reflector)
+	schema := r.ReflectSlice(&examples.User{})
+
+	assert.Equal(t, Version, schema.Version)
+	assert.Equal(t, "array", schema.Type)
+	require.NotNil(t, schema.Items)
+	assert.Equal(t, "#/$defs/User", schema.Items.Ref)
+	require.Contains(t, schema.Definitions, "User")
+	assert.Equal(t, "object", schema.Definitions["User"].Type)
+	assert.Empty(t, schema.ID)
+}
+
+func TestObjectKeywords(t *testing.T) {
+	type Scores struct {
+		Scores map[string]int `json:"scores" jsonschema:"minProperties=1,maxProperties=10"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Scores{})
+	i, found := schema.Properties.Get("scores")
+	require.True(t, found)
+
+	p := i.(*Schema)
+	assert.Equal(t, "object", p.Type)
+	assert.Equal(t, 1, p.MinProperties)
+	assert.Equal(t, 10, p.MaxProperties)
+}
+
+func TestIgnoreCustomTags(t *testing.T) {
+	type Tagged struct {
+		Name  string `json:"name" jsonschema:"title=the name,minLength=1,widget=input" jsonschema_extras:"foo=bar"`
+		Email string `json:"email,omitempty" jsonschema:"format=email,nullable" jsonschema_description:"contact"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Tagged{})
+	i, _ := schema.Properties.Get("name")
+	name := i.(*Schema)
+	assert.Equal(t, "the name", name.Title)
+	assert.Equal(t, 1, name.MinLength)
+	assert.Equal(t, "input", name.Widget)
+	assert.Equal(t, "bar", name.Extras["foo"])
+	i, _ = schema.Properties.Get("email")
+	assert.Len(t, i.(*Schema).OneOf, 2)
+
+	r = &Reflector{DoNotReference: true, IgnoreCustomTags: true}
+	schema = r.Reflect(&Tagged{})
+	i, found := schema.Properties.Get("name")
+	require.True(t, found)
+	assert.Equal(t, &Schema{Type: "string"}, i.(*Schema))
+	i, found = schema.Properties.Get("email")
+	require.True(t, found)
+	assert.Equal(t, &Schema{Type: "string"}, i.(*Schema))
+	assert.Equal(t, []string{"name"}, schema.Required)
+}
+
+func TestEqualsInTagValues(t *testing.T) {
+	type Equals struct {
+		Pair    string `json:"pair" jsonschema:"default=a=b,pattern=^[a-z]+=[a-z]+$"`
+		Heading string `json:"heading" jsonschema:"title=x=y"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Equals{})
+
+	i, found := schema.Properties.Get("pair")
+	require.True(t, found)
+	assert.Equal(t, "a=b", i.(*Schema).Default)
+	assert.Equal(t, "^[a-z]+=[a-z]+$", i.(*Schema).Pattern)
+
+	i, found = schema.Properties.Get("heading")
+	require.True(t, found)
+	assert.Equal(t, "x=y", i.(*Schema).Title)
+}
+
+type Email string
+
+type Contacts struct {
+	Primary   Email   `json:"primary"`
+	Secondary Email   `json:"secondary"`
+	Others    []Email `json:"others"`
+	Note      string  `json:"note"`
+}
+
+func TestNamedScalarsAsDefs(t *testing.T) {
+	r := &Reflector{NamedScalarsAsDefs: true}
+	schema := r.Reflect(&Contacts{})
+
+	require.Contains(t, schema.Definitions, "Email")
+	assert.Equal(t, "string", schema.Definitions["Email"].Type)
+
+	props := schema.Definitions["Contacts"].Properties
+	for _, name := range []string{"primary", "secondary"} {
+		i, found := props.Get(name)
+		require.True(t, found)
+		assert.Equal(t, "#/$defs/Email", i.(*Schema).Ref)
+	}
+	i, _ := props.Get("others")
+	assert.Equal(t, "#/$defs/Email", i.(*Schema).Items.Ref)
+	i, _ = props.Get("note")
+	assert.Equal(t, "string", i.(*Schema).Type)
+
+	r = new(Reflector)
+	schema = r.Reflect(&Contacts{})
+	assert.NotContains(t, schema.Definitions, "Email")
+
+	// keywords tagged on a named scalar field sit beside its reference
+	type Signup struct {
+		Work Email `json:"work" jsonschema:"minLength=5,enum=a@b.io,enum=c@d.io,format=email"`
+	}
+	r = &Reflector{NamedScalarsAsDefs: true}
+	schema = r.Reflect(&Signup{})
+	work, _ := schema.Definitions["Signup"].Properties.Get("work")
+	assert.Equal(t, &Schema{
+		Ref:       "#/$defs/Email",
+		MinLength: 5,
+		Enum:      []interface{}{"a@b.io", "c@d.io"},
+		Format:    "email",
+	}, work.(*Schema))
+	assert.Equal(t, &Schema{Type: "string"}, schema.Definitions["Email"])
+
+	r.SchemaVersion = "http://json-schema.org/draft-07/schema#"
+	schema = r.Reflect(&Signup{})
+	work, _ = schema.Definitions["Signup"].Properties.Get("work")
+	assert.Empty(t, work.(*Schema).Ref)
+	assert.Equal(t, 5, work.(*Schema).MinLength)
+	assert.Equal(t, "#/$defs/Email", work.(*Schema).AllOf[0].Ref)
+}
+
+type NotExtended struct {
+	Code string `json:"code"`
+}
+
+func (NotExtended) JSONSchemaExtend(base *Schema) {
+	val, _ := base.Properties.Get("code")
+	val.(*Schema).SetNot(&Schema{Enum: []interface{}{"a", "b"}})
+}
+
+func TestNotKeyword(t *testing.T) {
+	type NotTagged struct {
+		Name  string `json:"name" jsonschema:"not_const="`
+		Count int    `json:"count" jsonschema:"not_const=0"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&NotTagged{})
+	b, err := json.Marshal(schema.Properties)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":{"type":"string","not":{"const":""}},"count":{"type":"integer","not":{"const":0}}}`, string(b))
+
+	schema = r.Reflect(&NotExtended{})
+	i, _ := schema.Properties.Get("code")
+	require.NotNil(t, i.(*Schema).Not)
+	assert.Equal(t, []interface{}{"a", "b"}, i.(*Schema).Not.Enum)
+}
+
+func TestReflectMany(t *testing.T) {
+	type CreateRequest struct {
+		Name LookupName `json:"name"`
+	}
+	type UpdateRequest struct {
+		ID   string      `json:"id"`
+		Name *LookupName `json:"name"`
+	}
+
+	r := new(Reflector)
+	schema := r.ReflectMany(&CreateRequest{}, &UpdateRequest{})
+
+	assert.Equal(t, Version, schema.Version)
+	require.Len(t, schema.OneOf, 2)
+	assert.Equal(t, "#/$defs/CreateRequest", schema.OneOf[0].Ref)
+	assert.Equal(t, "#/$defs/UpdateRequest", schema.OneOf[1].Ref)
+	assert.Len(t, schema.Definitions, 3)
+	require.Contains(t, schema.Definitions, "LookupName")
+
+	for _, name := range []string{"CreateRequest", "UpdateRequest"} {
+		i, found := schema.Definitions[name].Properties.Get("name")
+		require.True(t, found)
+		assert.Equal(t, "#/$defs/LookupName", i.(*Schema).Ref)
+	}
+
+	// the document wide options apply as with Reflect
+	type Order struct {
+		Status string `json:"status" jsonschema:"enum=new,enum=paid,enum=sent"`
+		Kind   string `json:"kind" jsonschema:"enum=a,enum=b"`
+	}
+	r = &Reflector{MaxEnumValues: 2, DropOversizedEnums: true, EnumExamples: true}
+	schema = r.ReflectMany(&Order{})
+	order := schema.Definitions["Order"]
+	status, _ := order.Properties.Get("status")
+	assert.Nil(t, status.(*Schema).Enum)
+	kind, _ := order.Properties.Get("kind")
+	assert.Equal(t, []interface{}{"a", "b"}, kind.(*Schema).Examples)
+
+	r = &Reflector{ContentAddressedDefs: true}
+	schema = r.ReflectMany(&Order{})
+	assert.Equal(t, definitionNames(r.Reflect(&Order{}).Definitions), definitionNames(schema.Definitions))
+	assert.True(t, strings.HasPrefix(schema.OneOf[0].Ref, "#/$defs/def_"), schema.OneOf[0].Ref)
+	assert.Empty(t, schema.SelfValidate())
+}
+
+func TestFieldAnchorRef(t *testing.T) {
+	type Addresses struct {
+		Billing  string `json:"billing" jsonschema:"anchor=Address,minLength=5"`
+		Shipping string `json:"shipping" jsonschema:"anchor_ref=Address"`
+	}
+
+	r := new(Reflector)
+	schema := r.Reflect(&Addresses{})
+	props := schema.Definitions["Addresses"].Properties
+
+	i, _ := props.Get("billing")
+	assert.Equal(t, "Address", i.(*Schema).Anchor)
+	b, err := json.Marshal(i)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"$anchor":"Address","type":"string","minLength":5}`, string(b))
+
+	i, _ = props.Get("shipping")
+	assert.Equal(t, "#Address", i.(*Schema).Ref)
+}
+
+func TestTypeArray(t *testing.T) {
+	type Union struct {
+		Value interface{} `json:"value" jsonschema:"type=string;integer"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Union{})
+	i, _ := schema.Properties.Get("value")
+	p := i.(*Schema)
+	assert.Equal(t, []string{"string", "integer"}, p.Types)
+
+	b, err := json.Marshal(p)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":["string","integer"]}`, string(b))
+
+	parsed := new(Schema)
+	require.NoError(t, json.Unmarshal(b, parsed))
+	assert.Equal(t, []string{"string", "integer"}, parsed.Types)
+	assert.Empty(t, parsed.Type)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"string"}`), parsed))
+	assert.Equal(t, "string", parsed.Type)
+}
+
+func TestBigNumbers(t *testing.T) {
+	type Balances struct {
+		Total *big.Int   `json:"total"`
+		Rate  big.Float  `json:"rate"`
+		Parts []*big.Int `json:"parts"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Balances{})
+	b, err := json.Marshal(schema.Properties)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"total":{"type":"number"},"rate":{"type":"number"},"parts":{"type":"array","items":{"type":"number"}}}`, string(b))
+
+	r = &Reflector{DoNotReference: true, BigIntAsString: true}
+	schema = r.Reflect(&Balances{})
+	i, _ := schema.Properties.Get("total")
+	assert.Equal(t, &Schema{Type: "string", Pattern: "^-?[0-9]+$"}, i.(*Schema))
+	i, _ = schema.Properties.Get("rate")
+	assert.Equal(t, "number", i.(*Schema).Type)
+}
+
+func TestSchemaTagKey(t *testing.T) {
+	type Legacy struct {
+		Name    string `json:"name" js:"title=the name,minLength=1" jsonschema:"title=ignored"`
+		Hidden  string `json:"hidden" js:"-"`
+		Comment string `json:"comment,omitempty" js:"required" js_description:"a comment" js_extras:"foo=bar"`
+	}
+
+	r := &Reflector{DoNotReference: true, SchemaTagKey: "js", RequiredFromJSONSchemaTags: true}
+	schema := r.Reflect(&Legacy{})
+
+	i, found := schema.Properties.Get("name")
+	require.True(t, found)
+	assert.Equal(t, "the name", i.(*Schema).Title)
+	assert.Equal(t, 1, i.(*Schema).MinLength)
+
+	_, found = schema.Properties.Get("hidden")
+	assert.False(t, found)
+
+	i, found = schema.Properties.Get("comment")
+	require.True(t, found)
+	assert.Equal(t, "a comment", i.(*Schema).Description)
+	assert.Equal(t, "bar", i.(*Schema).Extras["foo"])
+	assert.Equal(t, []string{"comment"}, schema.Required)
+}
+
+func TestProfiles(t *testing.T) {
+	type Account struct {
+		ID       string `json:"id"`
+		Email    string `json:"email" jsonschema:"profiles=public;internal"`
+		Password string `json:"password" jsonschema:"profiles=internal"`
+	}
+
+	keys := func(r *Reflector) []string {
+		return r.Reflect(&Account{}).Properties.Keys()
+	}
+
+	assert.Equal(t, []string{"id", "email", "password"}, keys(&Reflector{DoNotReference: true}))
+	assert.Equal(t, []string{"id", "email"}, keys(&Reflector{DoNotReference: true, Profile: "public"}))
+	assert.Equal(t, []string{"id", "email", "password"}, keys(&Reflector{DoNotReference: true, Profile: "internal"}))
+}
+
+func TestKeepEmptySchema(t *testing.T) {
+	b, err := json.Marshal(NewSchema(""))
+	require.NoError(t, err)
+	assert.Equal(t, "true", string(b))
+
+	placeholder := NewSchema("")
+	placeholder.KeepEmpty = true
+	b, err = json.Marshal(placeholder)
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(b))
+
+	b, err = json.Marshal(TrueSchema)
+	require.NoError(t, err)
+	assert.Equal(t, "true", string(b))
+}
+
+type StringerEmbed struct {
+	fmt.Stringer
+	Name string `json:"name"`
+}
+
+type StringerNamed struct {
+	fmt.Stringer `json:"label,omitempty"`
+	Name         string `json:"name"`
+}
+
+func TestEmbeddedInterface(t *testing.T) {
+	r := new(Reflector)
+	schema := r.Reflect(&StringerEmbed{})
+	def := schema.Definitions["StringerEmbed"]
+	assert.Equal(t, []string{"name"}, def.Properties.Keys())
+	assert.Equal(t, []string{"name"}, def.Required)
+	assert.Equal(t, FalseSchema, def.AdditionalProperties)
+
+	schema = r.Reflect(&StringerNamed{})
+	def = schema.Definitions["StringerNamed"]
+	assert.Equal(t, []string{"label", "name"}, def.Properties.Keys())
+	assert.Equal(t, []string{"name"}, def.Required)
+}
+
+func TestDebugTree(t *testing.T) {
+	type TreeChild struct {
+		Value float64 `json:"value"`
+	}
+	type TreeRoot struct {
+		ID       int               `json:"id"`
+		Email    string            `json:"email,omitempty" jsonschema:"format=email"`
+		Children []TreeChild       `json:"children"`
+		Labels   map[string]string `json:"labels,omitempty"`
+		Extra    interface{}       `json:"extra,omitempty"`
+	}
+
+	r := &Reflector{Anonymous: true}
+	expected := `#: $ref #/$defs/TreeRoot
+$defs/TreeChild: object
+  value*: number
+$defs/TreeRoot: object
+  id*: integer
+  email: string (email)
+  children*: array
+    []: $ref #/$defs/TreeChild
+  labels: object
+    /.*/: string
+  extra: any
+`
+	assert.Equal(t, expected, r.Reflect(&TreeRoot{}).DebugTree())
+}
+
+func TestAutoFormat(t *testing.T) {
+	type Profile struct {
+		Email      string  `json:"email"`
+		BackupMail string  `json:"backup_mail"`
+		URL        *string `json:"url"`
+		AvatarURL  string  `json:"avatar_url"`
+		Homepage   string  `json:"homepage" jsonschema:"format=hostname"`
+		Name       string  `json:"name"`
+	}
+
+	formats := func(r *Reflector) map[string]string {
+		res := map[string]string{}
+		props := r.Reflect(&Profile{}).Properties
+		for _, key := range props.Keys() {
+			v, _ := props.Get(key)
+			res[key] = v.(*Schema).Format
+		}
+		return res
+	}
+
+	assert.Equal(t, map[string]string{
+		"email": "email", "backup_mail": "", "url": "uri", "avatar_url": "uri", "homepage": "hostname", "name": "",
+	}, formats(&Reflector{DoNotReference: true, AutoFormat: true}))
+
+	assert.Equal(t, map[string]string{
+		"email": "", "backup_mail": "", "url": "", "avatar_url": "", "homepage": "hostname", "name": "",
+	}, formats(&Reflector{DoNotReference: true}))
+
+	assert.Equal(t, map[string]string{
+		"email": "email", "backup_mail": "email", "url": "uri", "avatar_url": "uri", "homepage": "hostname", "name": "",
+	}, formats(&Reflector{DoNotReference: true, AutoFormat: true, FormatGuesser: func(f reflect.StructField) string {
+		if strings.HasSuffix(f.Name, "Mail") {
+			return "email"
+		}
+		return DefaultFormatGuesser(f)
+	}}))
+
+	// url and uri only match as a word of their own
+	for name, format := range map[string]string{
+		"URL": "uri", "Uri": "uri", "AvatarURL": "uri", "AvatarUrl": "uri", "Avatar_url": "uri", "RESOURCE_URI": "uri",
+		"Curl": "", "Hurl": "", "CURL": "", "Securi": "", "Purl": "",
+	} {
+		assert.Equal(t, format, DefaultFormatGuesser(reflect.StructField{Name: name}), name)
+	}
+}
+
+func TestReflectByName(t *testing.T) {
+	r := new(Reflector)
+	r.RegisterType("user", &examples.User{})
+	r.RegisterType("lookup", LookupName{})
+
+	s, err := r.ReflectByName("user")
+	require.NoError(t, err)
+	assert.Equal(t, r.Reflect(&examples.User{}), s)
+
+	s, err = r.ReflectByName("lookup")
+	require.NoError(t, err)
+	assert.Equal(t, "#/$defs/LookupName", s.Ref)
+
+	_, err = r.ReflectByName("missing")
+	assert.Error(t, err)
+}
+
+func TestWriteOnlyByName(t *testing.T) {
+	type Credentials struct {
+		Username    string `json:"username"`
+		Password    string `json:"password"`
+		APISecret   string `json:"api_secret"`
+		AccessToken string `json:"access_token"`
+	}
+
+	writeOnly := func(r *Reflector) []string {
+		var res []string
+		props := r.Reflect(&Credentials{}).Properties
+		for _, key := range props.Keys() {
+			v, _ := props.Get(key)
+			if v.(*Schema).WriteOnly {
+				res = append(res, key)
+			}
+		}
+		return res
+	}
+
+	assert.Empty(t, writeOnly(&Reflector{DoNotReference: true}))
+	assert.Equal(t, []string{"password", "api_secret", "access_token"}, writeOnly(&Reflector{
+		DoNotReference:  true,
+		WriteOnlyByName: []string{"password", "secret", "Token"},
+	}))
+}
+
+func TestSchemaEqual(t *testing.T) {
+	propsA := orderedmap.New()
+	propsA.Set("a", &Schema{Type: "string"})
+	propsA.Set("b", &Schema{Type: "integer", Enum: []interface{}{}})
+	propsB := orderedmap.New()
+	propsB.Set("b", &Schema{Type: "integer"})
+	propsB.Set("a", &Schema{Type: "string", Required: []string{}})
+
+	a := &Schema{Type: "object", Properties: propsA, Extras: map[string]interface{}{"x": []string{"1", "2"}, "y": true}}
+	b := &Schema{Type: "object", Properties: propsB, Extras: map[string]interface{}{"y": true, "x": []interface{}{"1", "2"}}}
+	assert.False(t, reflect.DeepEqual(a, b))
+	assert.True(t, a.Equal(b))
+	assert.True(t, b.Equal(a))
+
+	b.Extras["y"] = false
+	assert.False(t, a.Equal(b))
+
+	assert.True(t, (&Schema{}).Equal(TrueSchema))
+	assert.False(t, TrueSchema.Equal(FalseSchema))
+	assert.False(t, a.Equal(nil))
+	assert.True(t, (*Schema)(nil).Equal(nil))
+}
+
+var countedSchemaCalls int
+
+type CountedSchema string
+
+func (CountedSchema) JSONSchema() *Schema {
+	countedSchemaCalls++
+	return &Schema{Type: "string", Pattern: "^[a-z]+$"}
+}
+
+func TestCustomSchemaSliceItems(t *testing.T) {
+	type Collections struct {
+		First  []CountedSchema  `json:"first"`
+		Second []CountedSchema  `json:"second"`
+		Ptrs   []*CountedSchema `json:"ptrs"`
+		Single CountedSchema    `json:"single"`
+	}
+
+	countedSchemaCalls = 0
+	r := new(Reflector)
+	schema := r.Reflect(&Collections{})
+
+	assert.Equal(t, 1, countedSchemaCalls)
+	assert.Len(t, schema.Definitions, 2)
+	require.Contains(t, schema.Definitions, "CountedSchema")
+
+	props := schema.Definitions["Collections"].Properties
+	for _, key := range []string{"first", "second", "ptrs"} {
+		v, _ := props.Get(key)
+		assert.Equal(t, "#/$defs/CountedSchema", v.(*Schema).Items.Ref, key)
+	}
+	v, _ := props.Get("single")
+	assert.Equal(t, "#/$defs/CountedSchema", v.(*Schema).Ref)
+}
+
+func TestDescriptionMaxLength(t *testing.T) {
+	type Documented struct {
+		Commented string `json:"commented"`
+		Tagged    string `json:"tagged" jsonschema:"description=an explicit description that is long"`
+	}
+
+	base := fullyQualifiedTypeName(reflect.TypeOf(Documented{}))
+	r := &Reflector{
+		DescriptionMaxLength: 10,
+		CommentMap: map[string]string{
+			base:                "Documented is a type with a long doc comment.",
+			base + ".Commented": "Commented field docs",
+			base + ".Tagged":    "Tagged field docs that are ignored",
+		},
+	}
+	schema := r.Reflect(&Documented{})
+	def := schema.Definitions["Documented"]
+	assert.Equal(t, "Documented...", def.Description)
+
+	v, _ := def.Properties.Get("commented")
+	assert.Equal(t, "Commented...", v.(*Schema).Description)
+	v, _ = def.Properties.Get("tagged")
+	assert.Equal(t, "an explicit description that is long", v.(*Schema).Description)
+
+	r.DescriptionMaxLength = 0
+	schema = r.Reflect(&Documented{})
+	assert.Equal(t, "Documented is a type with a long doc comment.", schema.Definitions["Documented"].Description)
+}
+
+func TestReflectWithExamples(t *testing.T) {
+	type Sample struct {
+		Name  string   `json:"name"`
+		Age   int      `json:"age"`
+		Admin bool     `json:"admin"`
+		Tags  []string `json:"tags,omitempty"`
+	}
+
+	r := new(Reflector)
+	schema := r.ReflectWithExamples(
+		Sample{Name: "joe", Age: 30, Tags: []string{"a"}},
+		&Sample{Name: "lucy", Age: 30, Admin: true},
+		Sample{Name: "joe"},
+	)
+	props := schema.Definitions["Sample"].Properties
+
+	v, _ := props.Get("name")
+	assert.Equal(t, []interface{}{"joe", "lucy"}, v.(*Schema).Examples)
+	v, _ = props.Get("age")
+	assert.Equal(t, []interface{}{float64(30)}, v.(*Schema).Examples)
+	v, _ = props.Get("admin")
+	assert.Equal(t, []interface{}{true}, v.(*Schema).Examples)
+	v, _ = props.Get("tags")
+	assert.Equal(t, []interface{}{[]interface{}{"a"}}, v.(*Schema).Examples)
+
+	assert.Nil(t, r.ReflectWithExamples())
+
+	// values are matched to their fields when keys are renamed
+	r = &Reflector{KeyNamer: strings.ToUpper}
+	schema = r.ReflectWithExamples(Sample{Name: "joe", Age: 30})
+	props = schema.Definitions["Sample"].Properties
+	v, _ = props.Get("NAME")
+	assert.Equal(t, []interface{}{"joe"}, v.(*Schema).Examples)
+	v, _ = props.Get("AGE")
+	assert.Equal(t, []interface{}{float64(30)}, v.(*Schema).Examples)
+}
+
+func TestSchemaSimplify(t *testing.T) {
+	props := orderedmap.New()
+	props.Set("name", &Schema{
+		Description: "the name",
+		OneOf:       []*Schema{{Type: "string", MinLength: 1}},
+	})
+	props.Set("value", &Schema{
+		AnyOf: []*Schema{{Type: "integer", OneOf: []*Schema{{Minimum: 1}}}},
+	})
+	props.Set("conflict", &Schema{
+		Type:  "string",
+		OneOf: []*Schema{{Type: "integer"}},
+	})
+	props.Set("empty", &Schema{Type: "boolean", OneOf: []*Schema{}, AnyOf: []*Schema{}})
+	props.Set("any", &Schema{Title: "any", OneOf: []*Schema{TrueSchema}})
+	props.Set("both", &Schema{
+		OneOf: []*Schema{{Type: "string"}},
+		AnyOf: []*Schema{{MaxLength: 5}},
+	})
+	s := &Schema{Type: "object", Properties: props}
+
+	s.Simplify()
+
+	v, _ := props.Get("name")
+	assert.Equal(t, &Schema{Type: "string", MinLength: 1, Description: "the name"}, v.(*Schema))
+	v, _ = props.Get("value")
+	assert.Equal(t, &Schema{Type: "integer", Minimum: 1}, v.(*Schema))
+	v, _ = props.Get("conflict")
+	assert.Len(t, v.(*Schema).OneOf, 1)
+	v, _ = props.Get("empty")
+	assert.Equal(t, &Schema{Type: "boolean"}, v.(*Schema))
+	v, _ = props.Get("any")
+	assert.Equal(t, &Schema{Title: "any"}, v.(*Schema))
+	assert.NotNil(t, TrueSchema.boolean)
+	// every single-member combinator is folded
+	v, _ = props.Get("both")
+	assert.Equal(t, &Schema{Type: "string", MaxLength: 5}, v.(*Schema))
+}
+
+func TestSyncFieldsSkipped(t *testing.T) {
+	type Guarded struct {
+		Mu    sync.Mutex    `json:"mu"`
+		Cache *sync.Map     `json:"cache"`
+		Last  atomic.Value  `json:"last"`
+		Lock  *sync.RWMutex `json:"lock"`
+		Name  string        `json:"name"`
+	}
+
+	r := new(Reflector)
+	schema := r.Reflect(&Guarded{})
+	def := schema.Definitions["Guarded"]
+	assert.Equal(t, []string{"name"}, def.Properties.Keys())
+	assert.Equal(t, []string{"name"}, def.Required)
+	assert.Len(t, schema.Definitions, 1)
+}
+
+func TestWriteJSON(t *testing.T) {
+	schemas := []*Schema{
+		new(Reflector).Reflect(&TestUser{}),
+		(&Reflector{DoNotReference: true}).Reflect(&TestUser{}),
+		prepareCommentReflector(t).Reflect(&examples.User{}),
+		(&Reflector{RequiredFromJSONSchemaTags: true}).Reflect(&RootOneOf{}),
+		{Types: []string{"string", "null"}, Extras: map[string]interface{}{"a": "<b>"}},
+		TrueSchema,
+		{},
+	}
+
+	for _, s := range schemas {
+		expected, err := json.Marshal(s)
+		require.NoError(t, err)
+
+		var buf strings.Builder
+		require.NoError(t, s.WriteJSON(&buf))
+		assert.Equal(t, string(expected), buf.String())
+	}
+}
+
+func TestDiscriminatorTag(t *testing.T) {
+	type Shape struct {
+		Kind   string  `json:"kind" jsonschema:"discriminator,enum=circle,enum=square"`
+		Radius float64 `json:"radius,omitempty"`
+	}
+
+	r := new(Reflector)
+	schema := r.Reflect(&Shape{})
+	def := schema.Definitions["Shape"]
+	assert.Equal(t, map[string]interface{}{"propertyName": "kind"}, def.Extras["discriminator"])
+
+	v, _ := def.Properties.Get("kind")
+	assert.Equal(t, []interface{}{"circle", "square"}, v.(*Schema).Enum)
+	assert.Nil(t, v.(*Schema).Const)
+
+	b, err := json.Marshal(def)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"discriminator":{"propertyName":"kind"}`)
+}
+
+func TestIncludeJSONDashWithSchemaTag(t *testing.T) {
+	type Derived struct {
+		Name     string `json:"name"`
+		FullName string `json:"-" jsonschema:"readOnly=true,description=computed from name"`
+		Internal string `json:"-"`
+		Hidden   string `json:"-" jsonschema:"-"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	assert.Equal(t, []string{"name"}, r.Reflect(&Derived{}).Properties.Keys())
+
+	r.IncludeJSONDashWithSchemaTag = true
+	schema := r.Reflect(&Derived{})
+	assert.Equal(t, []string{"name", "FullName"}, schema.Properties.Keys())
+	assert.Equal(t, []string{"name"}, schema.Required)
+	v, _ := schema.Properties.Get("FullName")
+	assert.True(t, v.(*Schema).ReadOnly)
+	assert.Equal(t, "computed from name", v.(*Schema).Description)
+}
+
+func TestURLFormat(t *testing.T) {
+	type Links struct {
+		Self     url.URL  `json:"self"`
+		Relative url.URL  `json:"relative" jsonschema:"format=uri-reference"`
+		Intl     *url.URL `json:"intl" jsonschema:"format=iri"`
+	}
+
+	formats := func(r *Reflector) []string {
+		var res []string
+		props := r.Reflect(&Links{}).Properties
+		for _, key := range props.Keys() {
+			v, _ := props.Get(key)
+			res = append(res, v.(*Schema).Format)
+		}
+		return res
+	}
+
+	assert.Equal(t, []string{"uri", "uri-reference", "iri"}, formats(&Reflector{DoNotReference: true}))
+	assert.Equal(t, []string{"iri-reference", "uri-reference", "iri"}, formats(&Reflector{DoNotReference: true, URLFormat: "iri-reference"}))
+}
+
+type Payment struct {
+	CreditCard     string `json:"credit_card,omitempty"`
+	BillingAddress string `json:"billing_address,omitempty"`
+}
+
+func (Payment) JSONSchemaExtend(base *Schema) {
+	base.AddDependentSchema("credit_card", &Schema{Required: []string{"billing_address"}})
+}
+
+func TestDependentSchemas(t *testing.T) {
+	r := new(Reflector)
+	def := r.Reflect(&Payment{}).Definitions["Payment"]
+	require.Len(t, def.DependentSchemas, 1)
+
+	b, err := json.Marshal(def.DependentSchemas)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"credit_card":{"required":["billing_address"]}}`, string(b))
+}
+
+type Date struct {
+	Year, Month, Day int
+}
+
+type Duration int64
+
+func TestFormatMapper(t *testing.T) {
+	type Event struct {
+		On      Date     `json:"on"`
+		Until   *Date    `json:"until"`
+		Length  Duration `json:"length"`
+		Created Date     `json:"created" jsonschema:"description=creation date"`
+	}
+
+	r := &Reflector{FormatMapper: map[reflect.Type]TypeFormat{
+		reflect.TypeOf(Date{}):      {Type: "string", Format: "date"},
+		reflect.TypeOf(Duration(0)): {Type: "string", Format: "duration"},
+	}}
+	schema := r.Reflect(&Event{})
+	assert.NotContains(t, schema.Definitions, "Date")
+
+	props := schema.Definitions["Event"].Properties
+	b, err := json.Marshal(props)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"on": {"type": "string", "format": "date"},
+		"until": {"type": "string", "format": "date"},
+		"length": {"type": "string", "format": "duration"},
+		"created": {"type": "string", "format": "date", "description": "creation date"}
+	}`, string(b))
+}
+
+type TitledDate string
+
+func (TitledDate) JSONSchema() *Schema {
+	return &Schema{Type: "string", Title: "Calendar date"}
+}
+
+type UserProfile struct {
+	Name    LookupName `json:"name"`
+	Joined  TitledDate `json:"joined"`
+	HTTPURL string     `json:"http_url"`
+}
+
+func TestTitleFromName(t *testing.T) {
+	r := &Reflector{TitleFromName: true}
+	schema := r.Reflect(&UserProfile{})
+
+	assert.Equal(t, "User Profile", schema.Definitions["UserProfile"].Title)
+	assert.Equal(t, "Lookup Name", schema.Definitions["LookupName"].Title)
+	assert.Equal(t, "Calendar date", schema.Definitions["TitledDate"].Title)
+	assert.Empty(t, new(Reflector).Reflect(&UserProfile{}).Definitions["UserProfile"].Title)
+
+	assert.Equal(t, "HTTP Server", humanizeName("HTTPServer"))
+	assert.Equal(t, "User", humanizeName("User"))
+}
+
+func TestAsJSONReader(t *testing.T) {
+	r := &Reflector{BaseSchemaID: "https://example.com/schemas"}
+	schema := r.Reflect(LookupUser{})
+
+	actual, err := ioutil.ReadAll(schema.AsJSONReader())
+	require.NoError(t, err)
+	expected, err := ioutil.ReadFile("fixtures/base_schema_id.json")
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+
+	_, err = ioutil.ReadAll((&Schema{Default: func() {}}).AsJSONReader())
+	assert.Error(t, err)
+}
+
+// Vendor accepts `x-` extension keys next to its declared properties
+type Vendor struct {
+	Name string `json:"name"`
+}
+
+func (Vendor) JSONSchemaExtend(base *Schema) {
+	base.PatternProperties = map[string]*Schema{
+		"^x-": {Type: "string"},
+	}
+}
+
+type Tree map[string]Tree
+
+type Page[T any] struct {
+	Items []T `json:"items"`
+	Total int `json:"total"`
+}
+
+type Index[K comparable, V any] map[K]V
+
+type MapItem struct {
+	Value string `json:"value"`
+}
+
+type MapKey string
+
+func TestMapStructValues(t *testing.T) {
+	type Maps struct {
+		ByName  map[string]MapItem  `json:"by_name"`
+		ByKey   map[MapKey]*MapItem `json:"by_key"`
+		ByIndex map[int]MapItem     `json:"by_index"`
+		ByID    map[uint64]MapItem  `json:"by_id"`
+	}
+
+	r := new(Reflector)
+	schema := r.Reflect(&Maps{})
+	require.Contains(t, schema.Definitions, "MapItem")
+	assert.Equal(t, "object", schema.Definitions["MapItem"].Type)
+
+	props := schema.Definitions["Maps"].Properties
+	for key, pattern := range map[string]string{
+		"by_name":  ".*",
+		"by_key":   ".*",
+		"by_index": "^-?[0-9]+$",
+		"by_id":    "^[0-9]+$",
+	} {
+		v, _ := props.Get(key)
+		p := v.(*Schema)
+		require.Len(t, p.PatternProperties, 1, key)
+		require.Contains(t, p.PatternProperties, pattern, key)
+		assert.Equal(t, "#/$defs/MapItem", p.PatternProperties[pattern].Ref, key)
+	}
+}
+
+func TestReflectFunc(t *testing.T) {
+	search := func(query string, limit int, filter *LookupName) ([]string, error) { return nil, nil }
+
+	r := new(Reflector)
+	schema, err := r.ReflectFunc(search, "query", "limit")
+	require.NoError(t, err)
+
+	assert.Equal(t, "object", schema.Type)
+	assert.Equal(t, []string{"query", "limit", "arg2"}, schema.Properties.Keys())
+	assert.Equal(t, []string{"query", "limit", "arg2"}, schema.Required)
+	assert.Equal(t, FalseSchema, schema.AdditionalProperties)
+
+	v, _ := schema.Properties.Get("limit")
+	assert.Equal(t, "integer", v.(*Schema).Type)
+	v, _ = schema.Properties.Get("arg2")
+	assert.Equal(t, "#/$defs/LookupName", v.(*Schema).Ref)
+	require.Contains(t, schema.Definitions, "LookupName")
+
+	_, err = r.ReflectFunc(LookupName{})
+	assert.Error(t, err)
+	_, err = r.ReflectFunc(nil)
+	assert.Error(t, err)
+}
+
+func TestToolSchema(t *testing.T) {
+	type WeatherArgs struct {
+		City string `json:"city" jsonschema:"description=city name"`
+		Days int    `json:"days,omitempty"`
+	}
+
+	r := &Reflector{ExpandedStruct: true, AllowAdditionalProperties: true}
+	tool, err := ToolSchema("get_weather", "Get the forecast", r.Reflect(&WeatherArgs{}))
+	require.NoError(t, err)
+
+	b, err := json.Marshal(tool)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"name": "get_weather",
+		"description": "Get the forecast",
+		"parameters": {
+			"type": "object",
+			"properties": {
+				"city": {"type": "string", "description": "city name"},
+				"days": {"type": "integer"}
+			},
+			"required": ["city"],
+			"additionalProperties": false
+		}
+	}`, string(b))
+
+	tool, err = ToolSchema("ping", "", nil)
+	require.NoError(t, err)
+	b, err = json.Marshal(tool)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"ping","parameters":{"type":"object","properties":{},"additionalProperties":false}}`, string(b))
+
+	// values that can't be marshaled are reported
+	_, err = ToolSchema("broken", "", &Schema{Type: "object", Default: make(chan int)})
+	assert.Error(t, err)
+}
+
+func TestOpenAPINullable(t *testing.T) {
+	type PetTag struct {
+		Label string `json:"label"`
+	}
+	type Pet struct {
+		Name  string  `json:"name"`
+		Owner *string `json:"owner" jsonschema:"nullable"`
+		Tag   *PetTag `json:"tag,omitempty" jsonschema:"nullable"`
+	}
+
+	r := &Reflector{OpenAPINullable: true}
+	schema := r.Reflect(&Pet{})
+	def := schema.Definitions["Pet"]
+
+	owner, _ := def.Properties.Get("owner")
+	b, err := json.Marshal(owner)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"string","nullable":true}`, string(b))
+
+	tag, _ := def.Properties.Get("tag")
+	b, err = json.Marshal(tag)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"allOf":[{"$ref":"#/$defs/PetTag"}],"nullable":true}`, string(b))
+}
+
+func TestBase64ByteLengths(t *testing.T) {
+	type Key struct {
+		Secret []byte `json:"secret" jsonschema:"minBytes=16,maxBytes=32"`
+		Name   string `json:"name" jsonschema:"maxBytes=32"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Key{})
+
+	secret, _ := schema.Properties.Get("secret")
+	assert.Equal(t, "base64", secret.(*Schema).ContentEncoding)
+	assert.Equal(t, 24, secret.(*Schema).MinLength)
+	assert.Equal(t, 44, secret.(*Schema).MaxLength)
+
+	name, _ := schema.Properties.Get("name")
+	assert.Zero(t, name.(*Schema).MaxLength)
+}
+
+func TestTimeInSliceAndMap(t *testing.T) {
+	type Schedule struct {
+		Slots     []time.Time          `json:"slots"`
+		Deadlines map[string]time.Time `json:"deadlines"`
+	}
+
+	r := &Reflector{}
+	schema := r.Reflect(&Schedule{})
+	def := schema.Definitions["Schedule"]
+	expected := &Schema{Type: "string", Format: "date-time"}
+
+	slots, _ := def.Properties.Get("slots")
+	assert.Equal(t, expected, slots.(*Schema).Items)
+
+	deadlines, _ := def.Properties.Get("deadlines")
+	assert.Equal(t, expected, deadlines.(*Schema).PatternProperties[".*"])
+
+	for name := range schema.Definitions {
+		assert.NotEqual(t, "Time", name)
+	}
+	assert.Len(t, schema.Definitions, 1)
+}
+
+func TestMaxEnumValues(t *testing.T) {
+	type Country struct {
+		Code  string `json:"code" jsonschema:"enum=CN,enum=US,enum=JP,enum=DE"`
+		Level int    `json:"level" jsonschema:"enum=1,enum=2"`
+	}
+
+	r := &Reflector{DoNotReference: true, MaxEnumValues: 3}
+	schema := r.Reflect(&Country{})
+
+	code, _ := schema.Properties.Get("code")
+	assert.Nil(t, code.(*Schema).Enum)
+	assert.Equal(t, "string", code.(*Schema).Type)
+	assert.Equal(t, "enum of 4 values omitted", code.(*Schema).Comments)
+
+	level, _ := schema.Properties.Get("level")
+	assert.Equal(t, []interface{}{1, 2}, level.(*Schema).Enum)
+
+	r.DropOversizedEnums = true
+	schema = r.Reflect(&Country{})
+	code, _ = schema.Properties.Get("code")
+	assert.Equal(t, &Schema{Type: "string"}, code)
+}
+
+func TestAnonymousStructDefs(t *testing.T) {
+	type Shipment struct {
+		From struct {
+			City string `json:"city"`
+		} `json:"from"`
+		To struct {
+			City string `json:"city"`
+		} `json:"to"`
+	}
+
+	r := &Reflector{AnonymousStructDefs: true}
+	schema := r.Reflect(&Shipment{})
+	require.Len(t, schema.Definitions, 2)
+
+	def := schema.Definitions["Shipment"]
+	from, _ := def.Properties.Get("from")
+	to, _ := def.Properties.Get("to")
+	assert.Equal(t, from, to)
+
+	ref := from.(*Schema).Ref
+	require.True(t, strings.HasPrefix(ref, "#/$defs/Anon"), ref)
+	anon := schema.Definitions[strings.TrimPrefix(ref, "#/$defs/")]
+	require.NotNil(t, anon)
+	_, ok := anon.Properties.Get("city")
+	assert.True(t, ok)
+
+	// the name is derived from the shape and stays stable across runs
+	again, _ := (&Reflector{AnonymousStructDefs: true}).Reflect(&Shipment{}).Definitions["Shipment"].Properties.Get("from")
+	assert.Equal(t, ref, again.(*Schema).Ref)
+
+	// a different shape landing on a taken name gets a suffix
+	c := r.forCall()
+	shape := reflect.TypeOf(Shipment{}.From)
+	base := c.anonStructName(shape)
+	other := reflect.TypeOf(struct{ Town string }{})
+	c.claimedNames[base] = other
+	assert.Equal(t, base+"_2", c.anonStructName(shape))
+	assert.Equal(t, base+"_2", c.anonStructName(shape))
+}
+
+func TestReflectSplit(t *testing.T) {
+	r := &Reflector{}
+	root, defs := r.ReflectSplit(reflect.TypeOf(&Contacts{}))
+
+	assert.Nil(t, root.Definitions)
+	assert.Equal(t, "#/$defs/Contacts", root.Ref)
+	require.Contains(t, defs, "Contacts")
+
+	// definitions of several reflections can be merged into one document
+	_, more := r.ReflectSplit(reflect.TypeOf(&Payment{}))
+	for name, def := range more {
+		defs[name] = def
+	}
+	assert.Contains(t, defs, "Contacts")
+	assert.Contains(t, defs, "Payment")
+}
+
+func TestExamplesTag(t *testing.T) {
+	type Server struct {
+		Host  string `json:"host" jsonschema:"examples=localhost|example.com|a\\|b"`
+		Port  int    `json:"port" jsonschema:"examples=80|443|8080"`
+		Proto string `json:"proto" jsonschema:"examples=http;https"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Server{})
+
+	host, _ := schema.Properties.Get("host")
+	assert.Equal(t, []interface{}{"localhost", "example.com", "a|b"}, host.(*Schema).Examples)
+
+	port, _ := schema.Properties.Get("port")
+	assert.Equal(t, []interface{}{80, 443, 8080}, port.(*Schema).Examples)
+
+	r.ExamplesSeparator = ";"
+	schema = r.Reflect(&Server{})
+	proto, _ := schema.Properties.Get("proto")
+	assert.Equal(t, []interface{}{"http", "https"}, proto.(*Schema).Examples)
+}
+
+func TestRecursiveMap(t *testing.T) {
+	r := &Reflector{}
+	schema := r.Reflect(Tree{})
+	assert.Equal(t, "#/$defs/Tree", schema.Ref)
+	assert.Equal(t, "#/$defs/Tree", schema.Definitions["Tree"].PatternProperties[".*"].Ref)
+
+	r = &Reflector{DoNotReference: true}
+	schema = r.Reflect(Tree{})
+	assert.Equal(t, "object", schema.Type)
+	assert.Equal(t, "#/$defs/Tree", schema.PatternProperties[".*"].Ref)
+	require.Contains(t, schema.Definitions, "Tree")
+	assert.Equal(t, "#/$defs/Tree", schema.Definitions["Tree"].PatternProperties[".*"].Ref)
+
+	// recursive structs are handled the same way
+	type Node struct {
+		Children []*Node `json:"children"`
+	}
+	schema = r.Reflect(&Node{})
+	children, _ := schema.Properties.Get("children")
+	assert.Equal(t, "#/$defs/Node", children.(*Schema).Items.Ref)
+	assert.Contains(t, schema.Definitions, "Node")
+
+	// no reflection state is left behind, even by a panic
+	type Broken struct {
+		Node Node          `json:"node"`
+		Ch   chan struct{} `json:"ch"`
+	}
+	_, err := r.ReflectSafe(&Broken{})
+	require.Error(t, err)
+	assert.Empty(t, r.inProgress)
+	assert.Equal(t, schema, r.Reflect(&Node{}))
+
+	// the other entry points keep the definitions recursive types refer to
+	slice := r.ReflectSlice(&Node{})
+	children, _ = slice.Items.Properties.Get("children")
+	assert.Equal(t, "#/$defs/Node", children.(*Schema).Items.Ref)
+	assert.Contains(t, slice.Definitions, "Node")
+	assert.Empty(t, slice.SelfValidate())
+
+	many := r.ReflectMany(&Node{}, "")
+	children, _ = many.OneOf[0].Properties.Get("children")
+	assert.Equal(t, "#/$defs/Node", children.(*Schema).Items.Ref)
+	assert.Equal(t, []string{"Node"}, definitionNames(many.Definitions))
+	assert.Empty(t, many.SelfValidate())
+}
+
+func TestTitleInterpolation(t *testing.T) {
+	type Account struct {
+		UserName string `json:"user_name" jsonschema:"title=$field"`
+		Contact  *Email `json:"contact" jsonschema:"title=$field ($type)"`
+		Plain    string `json:"plain" jsonschema:"title=Fixed"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Account{})
+
+	userName, _ := schema.Properties.Get("user_name")
+	assert.Equal(t, "User Name", userName.(*Schema).Title)
+
+	contact, _ := schema.Properties.Get("contact")
+	assert.Equal(t, "Contact (Email)", contact.(*Schema).Title)
+
+	plain, _ := schema.Properties.Get("plain")
+	assert.Equal(t, "Fixed", plain.(*Schema).Title)
+}
+
+func TestPrune(t *testing.T) {
+	type Credentials struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+	}
+	type Member struct {
+		Name     string        `json:"name"`
+		Secret   string        `json:"secret"`
+		Accounts []Credentials `json:"accounts"`
+	}
+
+	schema := (&Reflector{}).Reflect(&Member{})
+	var paths []string
+	pruned := schema.Prune(func(path string, s *Schema) bool {
+		paths = append(paths, path)
+		return path != "$defs.Member.secret" && path != "$defs.Credentials.password"
+	})
+
+	member := pruned.Definitions["Member"]
+	_, ok := member.Properties.Get("secret")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"name", "accounts"}, member.Required)
+
+	credentials := pruned.Definitions["Credentials"]
+	_, ok = credentials.Properties.Get("password")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"login"}, credentials.Required)
+
+	// the original schema is left untouched
+	_, ok = schema.Definitions["Member"].Properties.Get("secret")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"name", "secret", "accounts"}, schema.Definitions["Member"].Required)
+
+	// nested inline objects are visited with their full path
+	inline := (&Reflector{DoNotReference: true}).Reflect(&Member{}).Prune(func(path string, s *Schema) bool {
+		return path != "accounts.*.password"
+	})
+	accounts, _ := inline.Properties.Get("accounts")
+	_, ok = accounts.(*Schema).Items.Properties.Get("password")
+	assert.False(t, ok)
+	assert.Contains(t, paths, "$defs.Member.name")
+}
+
+func TestContentMediaType(t *testing.T) {
+	type Upload struct {
+		Avatar []byte `json:"avatar" jsonschema:"contentMediaType=image/png"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Upload{})
+
+	avatar, _ := schema.Properties.Get("avatar")
+	b, err := json.Marshal(avatar)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"string","contentEncoding":"base64","contentMediaType":"image/png"}`, string(b))
+}
+
+func TestSortRequired(t *testing.T) {
+	type Base struct {
+		Middle string `json:"middle"`
+	}
+	type Record struct {
+		Zeta  string `json:"zeta"`
+		Alpha string `json:"alpha"`
+		Base
+		Beta string `json:"beta,omitempty"`
+	}
+
+	schema := (&Reflector{DoNotReference: true}).Reflect(&Record{})
+	assert.Equal(t, []string{"zeta", "alpha", "middle"}, schema.Required)
+
+	schema = (&Reflector{DoNotReference: true, SortRequired: true}).Reflect(&Record{})
+	assert.Equal(t, []string{"alpha", "middle", "zeta"}, schema.Required)
+	assert.Equal(t, []string{"zeta", "alpha", "middle", "beta"}, schema.Properties.Keys())
+}
+
+func TestEnumJSON(t *testing.T) {
+	type Flags struct {
+		State interface{} `json:"state" jsonschema:"enum_json=true,enum_json=false,enum_json=null"`
+		Code  string      `json:"code" jsonschema:"enum_json=\"123\",enum_json=\"abc\""`
+		Mixed interface{} `json:"mixed" jsonschema:"enum_json=1.5,enum_json=[1\\,2],enum_json=bad"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Flags{})
+
+	state, _ := schema.Properties.Get("state")
+	assert.Equal(t, []interface{}{true, false, nil}, state.(*Schema).Enum)
+	b, err := json.Marshal(state)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"enum":[true,false,null]}`, string(b))
+
+	code, _ := schema.Properties.Get("code")
+	assert.Equal(t, []interface{}{"123", "abc"}, code.(*Schema).Enum)
+
+	mixed, _ := schema.Properties.Get("mixed")
+	assert.Equal(t, []interface{}{1.5, []interface{}{float64(1), float64(2)}}, mixed.(*Schema).Enum)
+}
+
+func TestValidateTag(t *testing.T) {
+	assert.Empty(t, ValidateTag(""))
+	assert.Empty(t, ValidateTag("required,minLength=1,enum=a,enum=b,pattern=^[a-z]+$,format=email"))
+	assert.Empty(t, ValidateTag(`enum_json=[1\,2],title=a\,b`))
+
+	tests := []struct {
+		tag    string
+		errors []string
+	}{
+		{"minLenght=1", []string{`unknown keyword "minLenght"`}},
+		{"minLength=abc", []string{`keyword "minLength": strconv.Atoi: parsing "abc": invalid syntax`}},
+		{"readOnly=yes", []string{`keyword "readOnly": strconv.ParseBool: parsing "yes": invalid syntax`}},
+		{"title=a,title=b", []string{`duplicate keyword "title"`}},
+		{"required=true", []string{`keyword "required" does not take a value`}},
+		{"maxItems", []string{`keyword "maxItems" requires a value`}},
+		{"format=phone", []string{`keyword "format": unsupported format "phone"`}},
+		{"pattern=[a-", []string{"keyword \"pattern\": error parsing regexp: missing closing ]: `[a-`"}},
+		{"enum_json=nope,min=1", []string{
+			`keyword "enum_json": invalid character 'o' in literal null (expecting 'u')`,
+			`unknown keyword "min"`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			errs := ValidateTag(tt.tag)
+			msgs := make([]string, len(errs))
+			for i, err := range errs {
+				msgs[i] = err.Error()
+			}
+			assert.Equal(t, tt.errors, msgs)
+		})
+	}
+}
+
+func TestNetipTypes(t *testing.T) {
+	type Route struct {
+		Gateway netip.Addr    `json:"gateway"`
+		Network netip.Prefix  `json:"network"`
+		Peers   []*netip.Addr `json:"peers"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Route{})
+
+	gateway, _ := schema.Properties.Get("gateway")
+	b, err := json.Marshal(gateway)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"string","anyOf":[{"format":"ipv4"},{"format":"ipv6"}]}`, string(b))
+
+	network, _ := schema.Properties.Get("network")
+	assert.Equal(t, &Schema{Type: "string"}, network)
+
+	peers, _ := schema.Properties.Get("peers")
+	assert.Equal(t, "string", peers.(*Schema).Items.Type)
+	assert.Len(t, peers.(*Schema).Items.AnyOf, 2)
+	assert.Nil(t, schema.Definitions)
+}
+
+func TestOnDefinition(t *testing.T) {
+	var names []string
+	r := &Reflector{
+		OnDefinition: func(name string, s *Schema, t reflect.Type) {
+			names = append(names, name)
+			if s.Extras == nil {
+				s.Extras = map[string]interface{}{}
+			}
+			s.Extras["x-go-type"] = t.String()
+		},
+	}
+	schema := r.Reflect(&Contacts{})
+
+	assert.ElementsMatch(t, []string{"Contacts"}, names)
+	assert.Equal(t, "jsonschema.Contacts", schema.Definitions["Contacts"].Extras["x-go-type"])
+
+	names = nil
+	schema = r.Reflect(CountedSchema(""))
+	assert.Equal(t, []string{"CountedSchema"}, names)
+	assert.Equal(t, "jsonschema.CountedSchema", schema.Definitions["CountedSchema"].Extras["x-go-type"])
+}
+
+func TestRequiredFromValidateTag(t *testing.T) {
+	type SignUp struct {
+		Email    string `json:"email" validate:"required,email"`
+		Nickname string `json:"nickname" validate:"omitempty,min=2"`
+		Referrer string `json:"referrer,omitempty" validate:"required_if=Source ad"`
+		Password string `json:"password,omitempty" validate:"required"`
+		Terms    bool   `json:"terms" jsonschema:"required"`
+	}
+
+	r := &Reflector{DoNotReference: true, RequiredFromValidateTag: true}
+	schema := r.Reflect(&SignUp{})
+	assert.Equal(t, []string{"email", "password"}, schema.Required)
+
+	r.RequiredFromJSONSchemaTags = true
+	schema = r.Reflect(&SignUp{})
+	assert.Equal(t, []string{"email", "password", "terms"}, schema.Required)
+}
+
+func TestImportValidateTags(t *testing.T) {
+	type Signup struct {
+		Name    string            `json:"name" validate:"required,min=3,max=10"`
+		Email   string            `json:"email" validate:"required,email"`
+		Site    string            `json:"site" validate:"omitempty,url"`
+		Code    string            `json:"code" validate:"len=6"`
+		Age     int               `json:"age" validate:"gte=18,lte=130"`
+		Plan    string            `json:"plan" validate:"oneof=free pro team"`
+		Level   int               `json:"level" validate:"oneof=1 2 3"`
+		Tags    []string          `json:"tags" validate:"min=1,max=5,dive,min=2"`
+		Labels  map[string]string `json:"labels" validate:"max=8"`
+		Unknown string            `json:"unknown" validate:"alphanum,min=abc"`
+	}
+
+	r := &Reflector{DoNotReference: true, ImportValidateTags: true}
+	schema := r.Reflect(&Signup{})
+
+	get := func(name string) string {
+		p, _ := schema.Properties.Get(name)
+		b, err := json.Marshal(p)
+		require.NoError(t, err)
+		return string(b)
+	}
+	assert.JSONEq(t, `{"type":"string","minLength":3,"maxLength":10}`, get("name"))
+	assert.JSONEq(t, `{"type":"string","format":"email"}`, get("email"))
+	assert.JSONEq(t, `{"type":"string","format":"uri"}`, get("site"))
+	assert.JSONEq(t, `{"type":"string","minLength":6,"maxLength":6}`, get("code"))
+	assert.JSONEq(t, `{"type":"integer","minimum":18,"maximum":130}`, get("age"))
+	assert.JSONEq(t, `{"type":"string","enum":["free","pro","team"]}`, get("plan"))
+	assert.JSONEq(t, `{"type":"integer","enum":[1,2,3]}`, get("level"))
+	assert.JSONEq(t, `{"type":"array","items":{"type":"string"},"minItems":1,"maxItems":5}`, get("tags"))
+	assert.JSONEq(t, `{"type":"object","patternProperties":{".*":{"type":"string"}},"maxProperties":8}`, get("labels"))
+	assert.JSONEq(t, `{"type":"string"}`, get("unknown"))
+
+	// without the option validate rules are not imported
+	schema = (&Reflector{DoNotReference: true}).Reflect(&Signup{})
+	assert.JSONEq(t, `{"type":"string"}`, get("name"))
+}
+
+func TestOnRequiredWithDefault(t *testing.T) {
+	type Settings struct {
+		Theme    string `json:"theme" jsonschema:"default=dark"`
+		Language string `json:"language,omitempty" jsonschema:"default=en"`
+		Timezone string `json:"timezone"`
+	}
+
+	schema := (&Reflector{DoNotReference: true}).Reflect(&Settings{})
+	assert.Equal(t, []string{"theme", "timezone"}, schema.Required)
+
+	var conflicts []string
+	r := &Reflector{
+		DoNotReference: true,
+		OnRequiredWithDefault: func(parentType reflect.Type, f reflect.StructField, fieldName string) bool {
+			conflicts = append(conflicts, parentType.Name()+"."+fieldName)
+			return false
+		},
+	}
+	schema = r.Reflect(&Settings{})
+	assert.Equal(t, []string{"Settings.theme"}, conflicts)
+	assert.Equal(t, []string{"timezone"}, schema.Required)
+	theme, _ := schema.Properties.Get("theme")
+	assert.Equal(t, "dark", theme.(*Schema).Default)
+}
+
+func TestRefSiblingsByDraft(t *testing.T) {
+	type Address struct {
+		City string `json:"city"`
+	}
+	type Order struct {
+		Shipping Address `json:"shipping" jsonschema:"description=where to ship"`
+		Billing  Address `json:"billing"`
+	}
+
+	get := func(schema *Schema, name string) string {
+		p, _ := schema.Definitions["Order"].Properties.Get(name)
+		b, err := json.Marshal(p)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	schema := (&Reflector{}).Reflect(&Order{})
+	assert.JSONEq(t, `{"$ref":"#/$defs/Address","description":"where to ship"}`, get(schema, "shipping"))
+	assert.JSONEq(t, `{"$ref":"#/$defs/Address"}`, get(schema, "billing"))
+
+	schema = (&Reflector{SchemaVersion: "http://json-schema.org/draft-07/schema#"}).Reflect(&Order{})
+	assert.Equal(t, "http://json-schema.org/draft-07/schema#", schema.Version)
+	assert.JSONEq(t, `{"allOf":[{"$ref":"#/$defs/Address"}],"description":"where to ship"}`, get(schema, "shipping"))
+	assert.JSONEq(t, `{"$ref":"#/$defs/Address"}`, get(schema, "billing"))
+}
+
+func TestFlattenAllOf(t *testing.T) {
+	name := &Schema{Type: "string", MinLength: 1, MaxLength: 50}
+	base := NewSchema("object")
+	base.Properties.Set("name", name)
+	base.Properties.Set("role", &Schema{Type: "string", Enum: []interface{}{"admin", "user", "guest"}})
+	base.Required = []string{"name"}
+	base.AdditionalProperties = FalseSchema
+
+	extra := NewSchema("object")
+	extra.Properties.Set("name", &Schema{Type: "string", MinLength: 3, MaxLength: 100})
+	extra.Properties.Set("role", &Schema{Enum: []interface{}{"user", "admin"}})
+	extra.Properties.Set("age", &Schema{Type: "integer", Minimum: 0})
+	extra.Required = []string{"age", "name"}
+
+	s := &Schema{Title: "Member", AllOf: []*Schema{base, extra}}
+	flat, err := s.FlattenAllOf()
+	require.NoError(t, err)
+
+	b, err := json.Marshal(flat)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"title": "Member",
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 3, "maxLength": 50},
+			"role": {"type": "string", "enum": ["admin", "user"]},
+			"age": {"type": "integer"}
+		},
+		"required": ["name", "age"],
+		"additionalProperties": false
+	}`, string(b))
+
+	// the source schema is left untouched
+	assert.Len(t, s.AllOf, 2)
+	assert.Equal(t, 1, name.MinLength)
+	assert.Equal(t, []string{"name"}, base.Required)
+
+	// incompatible members can't be merged
+	_, err = (&Schema{AllOf: []*Schema{{Type: "string"}, {Type: "integer"}}}).FlattenAllOf()
+	assert.EqualError(t, err, "关键词 Type 存在冲突")
+	_, err = (&Schema{AllOf: []*Schema{{Ref: "#/$defs/User"}}}).FlattenAllOf()
+	assert.Error(t, err)
+}
+
+func TestMaxProperties(t *testing.T) {
+	type Wide struct {
+		A string `json:"a"`
+		B string `json:"b"`
+		C struct {
+			D string `json:"d"`
+			E string `json:"e"`
+			F string `json:"f"`
+		} `json:"c"`
+		G string `json:"g"`
+	}
+
+	r := &Reflector{DoNotReference: true, MaxProperties: 2}
+	schema := r.Reflect(&Wide{})
+	assert.Equal(t, []string{"a", "b"}, schema.Properties.Keys())
+	assert.Equal(t, []string{"a", "b"}, schema.Required)
+	assert.Equal(t, "properties truncated to the first 2", schema.Comments)
+
+	r.MaxProperties = 3
+	schema = r.Reflect(&Wide{})
+	c, _ := schema.Properties.Get("c")
+	assert.Equal(t, []string{"d", "e", "f"}, c.(*Schema).Properties.Keys())
+	assert.Empty(t, c.(*Schema).Comments)
+	assert.Equal(t, []string{"a", "b", "c"}, schema.Properties.Keys())
+
+	// the note is added to any existing comment
+	r = &Reflector{DoNotReference: true, MaxProperties: 2, AnnotateProvenance: true}
+	schema = r.Reflect(&Wide{})
+	assert.Equal(t, "github.com/23233/jsonschema.Wide; properties truncated to the first 2", schema.Comments)
+}
+
+func TestGenericTypes(t *testing.T) {
+	type Listing struct {
+		Users    Page[Contacts]           `json:"users"`
+		UserRefs Page[*Contacts]          `json:"user_refs"`
+		Nested   Page[Page[int]]          `json:"nested"`
+		ByHandle Index[string, *Contacts] `json:"by_handle"`
+	}
+
+	r := &Reflector{}
+	schema := r.Reflect(&Listing{})
+	assert.ElementsMatch(t, []string{"Listing", "Contacts", "Page(Contacts)", "Page(*Contacts)", "Page(int)", "Page(Page(int))", "Index(string,*Contacts)"}, definitionNames(schema.Definitions))
+
+	def := schema.Definitions["Listing"]
+	users, _ := def.Properties.Get("users")
+	assert.Equal(t, "#/$defs/Page(Contacts)", users.(*Schema).Ref)
+	items, _ := schema.Definitions["Page(Contacts)"].Properties.Get("items")
+	assert.Equal(t, "#/$defs/Contacts", items.(*Schema).Items.Ref)
+
+	// pointer type arguments are named apart from their element
+	refs, _ := def.Properties.Get("user_refs")
+	assert.Equal(t, "#/$defs/Page(*Contacts)", refs.(*Schema).Ref)
+
+	nested, _ := schema.Definitions["Page(Page(int))"].Properties.Get("items")
+	assert.Equal(t, "#/$defs/Page(int)", nested.(*Schema).Items.Ref)
+
+	assert.Equal(t, "#/$defs/Contacts", schema.Definitions["Index(string,*Contacts)"].PatternProperties[".*"].Ref)
+
+	// the refs are valid URI references
+	for _, name := range definitionNames(schema.Definitions) {
+		u, err := url.Parse("#/$defs/" + name)
+		require.NoError(t, err)
+		assert.Equal(t, "#/$defs/"+name, u.String())
+	}
+
+	// the root of a generic type is referenced the same way
+	schema = r.Reflect(&Page[Contacts]{})
+	assert.Equal(t, "#/$defs/Page(Contacts)", schema.Ref)
+}
+
+func definitionNames(defs Definitions) []string {
+	res := make([]string, 0, len(defs))
+	for k := range defs {
+		res = append(res, k)
+	}
+	return res
+}
+
+func TestStrictTags(t *testing.T) {
+	type Valid struct {
+		Name  string   `json:"name" jsonschema:"minLength=1,enum=a,enum=b"`
+		Count int      `json:"count" jsonschema:"minimum=1,not_const=0"`
+		Tags  []string `json:"tags" jsonschema:"minItems=1"`
+		Email Email    `json:"email" jsonschema:"format=email"`
+		// enum and format apply to the items of arrays
+		Emails []string `json:"emails" jsonschema:"format=email"`
+		Labels []string `json:"labels" jsonschema:"enum=a,enum=b"`
+		Scores []int    `json:"scores" jsonschema:"enum=1,enum=2"`
+		// readOnly and writeOnly apply to any type
+		Version int      `json:"version" jsonschema:"readOnly=true"`
+		Secrets []string `json:"secrets" jsonschema:"writeOnly=true"`
+	}
+	r := &Reflector{StrictTags: true}
+	schema, err := r.ReflectSafe(&Valid{})
+	require.NoError(t, err)
+	assert.NotNil(t, schema)
+	valid := schema.Definitions["Valid"]
+	emails, _ := valid.Properties.Get("emails")
+	assert.Equal(t, "email", emails.(*Schema).Items.Format)
+	labels, _ := valid.Properties.Get("labels")
+	assert.Equal(t, []interface{}{"a", "b"}, labels.(*Schema).Items.Enum)
+	version, _ := valid.Properties.Get("version")
+	assert.True(t, version.(*Schema).ReadOnly)
+	secrets, _ := valid.Properties.Get("secrets")
+	assert.True(t, secrets.(*Schema).WriteOnly)
+
+	tests := []struct {
+		name  string
+		value interface{}
+		err   string
+	}{
+		{"type with oneof_type", &struct {
+			V interface{} `json:"v" jsonschema:"type=string,oneof_type=string;integer"`
+		}{}, ".V: conflicting keywords type, oneof_type and anyof_type"},
+		{"oneof_type with anyof_type", &struct {
+			V interface{} `json:"v" jsonschema:"oneof_type=string;integer,anyof_type=string"`
+		}{}, ".V: conflicting keywords type, oneof_type and anyof_type"},
+		{"enum on untyped field", &struct {
+			V interface{} `json:"v" jsonschema:"enum=a"`
+		}{}, `.V: keyword "enum" requires a string, integer or number`},
+		{"not_const on boolean", &struct {
+			V bool `json:"v" jsonschema:"not_const=true"`
+		}{}, `.V: keyword "not_const" requires a string, integer or number`},
+		{"keyword for another type", &struct {
+			V int `json:"v" jsonschema:"minLength=2"`
+		}{}, `.V: keyword "minLength" does not apply to integer`},
+		{"keyword on a referenced struct", &struct {
+			V Contacts `json:"v" jsonschema:"minItems=1"`
+		}{}, `.V: keyword "minItems" does not apply to object`},
+		{"enum on items of another type", &struct {
+			V []bool `json:"v" jsonschema:"enum=true"`
+		}{}, `.V: keyword "enum" requires items of string, integer or number`},
+		{"format on items of another type", &struct {
+			V []int `json:"v" jsonschema:"format=email"`
+		}{}, `.V: keyword "format" does not apply to items of integer`},
+		{"malformed tag", &struct {
+			V string `json:"v" jsonschema:"maxLenght=2"`
+		}{}, `.V: unknown keyword "maxLenght"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := r.ReflectSafe(tt.value)
+			require.Error(t, err)
+			assert.Nil(t, schema)
+			assert.Equal(t, tt.err, err.Error())
+
+			// without StrictTags the same tags are accepted
+			_, err = (&Reflector{}).ReflectSafe(tt.value)
+			assert.NoError(t, err)
+		})
+	}
+
+	// unsupported types are reported instead of panicking
+	_, err = (&Reflector{}).ReflectSafe(&struct {
+		C chan int `json:"c"`
+	}{})
+	assert.EqualError(t, err, "jsonschema: unsupported type chan int")
+}
+
+func TestRawMessageMap(t *testing.T) {
+	type Envelope struct {
+		Attributes map[string]json.RawMessage `json:"attributes"`
+		Indexed    map[int]json.RawMessage    `json:"indexed"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Envelope{})
+
+	attributes, _ := schema.Properties.Get("attributes")
+	b, err := json.Marshal(attributes)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"object","additionalProperties":true}`, string(b))
+
+	indexed, _ := schema.Properties.Get("indexed")
+	b, err = json.Marshal(indexed)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"object","patternProperties":{"^-?[0-9]+$":true},"additionalProperties":false}`, string(b))
+}
+
+func TestPreserveGoFieldName(t *testing.T) {
+	type Customer struct {
+		FullName string   `json:"full_name"`
+		Address  Contacts `json:"address"`
+		Nickname *string  `json:"nick" jsonschema:"nullable"`
+	}
+
+	r := &Reflector{PreserveGoFieldName: true}
+	schema := r.Reflect(&Customer{})
+	def := schema.Definitions["Customer"]
+
+	fullName, _ := def.Properties.Get("full_name")
+	assert.Equal(t, "FullName", fullName.(*Schema).Extras["x-go-name"])
+
+	address, _ := def.Properties.Get("address")
+	b, err := json.Marshal(address)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"$ref":"#/$defs/Contacts","x-go-name":"Address"}`, string(b))
+
+	nick, _ := def.Properties.Get("nick")
+	assert.Equal(t, "Nickname", nick.(*Schema).Extras["x-go-name"])
+	assert.Nil(t, nick.(*Schema).OneOf[0].Extras)
+
+	// definitions themselves are not annotated
+	assert.Nil(t, schema.Definitions["Contacts"].Extras)
+}
+
+func TestPatternPropertiesWithAdditionalPropertiesFalse(t *testing.T) {
+	schema := (&Reflector{DoNotReference: true}).Reflect(&Vendor{})
+
+	b, err := json.Marshal(schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id": "https://github.com/23233/jsonschema/vendor",
+		"type": "object",
+		"properties": {"name": {"type": "string"}},
+		"patternProperties": {"^x-": {"type": "string"}},
+		"additionalProperties": false,
+		"required": ["name"]
+	}`, string(b))
+
+	// both keywords sit on the same object, next to the declared properties
+	assert.Equal(t, []string{"name"}, schema.Properties.Keys())
+	require.Contains(t, schema.PatternProperties, "^x-")
+	assert.Equal(t, "string", schema.PatternProperties["^x-"].Type)
+	assert.Equal(t, FalseSchema, schema.AdditionalProperties)
+
+	// no validator is vendored, so apply the keyword rules of section 10.3.2 to
+	// decoded documents: additionalProperties only covers the keys matched by
+	// neither properties nor patternProperties
+	accepted := func(doc string) bool {
+		var instance map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(doc), &instance))
+		for key := range instance {
+			if _, ok := schema.Properties.Get(key); ok {
+				continue
+			}
+			matched := false
+			for pattern := range schema.PatternProperties {
+				matched = matched || regexp.MustCompile(pattern).MatchString(key)
+			}
+			if !matched && schema.AdditionalProperties == FalseSchema {
+				return false
+			}
+		}
+		return true
+	}
+	assert.True(t, accepted(`{"name":"acme","x-region":"eu"}`))
+	assert.False(t, accepted(`{"name":"acme","region":"eu"}`))
+}
+
+func TestEnumTitled(t *testing.T) {
+	type Order struct {
+		Fruit    string `json:"fruit" jsonschema:"enum_titled=a:Apple;b:Banana;c"`
+		Priority int    `json:"priority" jsonschema:"enum_titled=1:Low;2:High"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Order{})
+
+	fruit, _ := schema.Properties.Get("fruit")
+	b, err := json.Marshal(fruit)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "string",
+		"oneOf": [
+			{"const": "a", "title": "Apple"},
+			{"const": "b", "title": "Banana"},
+			{"const": "c"}
+		]
+	}`, string(b))
+
+	priority, _ := schema.Properties.Get("priority")
+	b, err = json.Marshal(priority)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"integer","oneOf":[{"const":1,"title":"Low"},{"const":2,"title":"High"}]}`, string(b))
+
+	assert.Empty(t, ValidateTag("enum_titled=a:Apple;b:Banana"))
+}
+
+func TestErrorAsString(t *testing.T) {
+	type Result struct {
+		Value string `json:"value"`
+		Err   error  `json:"error,omitempty"`
+	}
+
+	schema := (&Reflector{DoNotReference: true}).Reflect(&Result{})
+	errSchema, _ := schema.Properties.Get("error")
+	assert.Equal(t, &Schema{}, errSchema)
+
+	schema = (&Reflector{DoNotReference: true, ErrorAsString: true}).Reflect(&Result{})
+	errSchema, _ = schema.Properties.Get("error")
+	assert.Equal(t, &Schema{Type: "string"}, errSchema)
+	assert.Equal(t, []string{"value"}, schema.Required)
+}
+
+func TestMetaTag(t *testing.T) {
+	type Invoice struct {
+		Card   string `json:"card" jsonschema_meta:"group=billing,icon=card"`
+		Amount int    `json:"amount"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Invoice{})
+
+	card, _ := schema.Properties.Get("card")
+	assert.Equal(t, map[string]interface{}{"group": "billing", "icon": "card"}, card.(*Schema).MetaData)
+	icon, ok := card.(*Schema).GetMeta("icon")
+	assert.True(t, ok)
+	assert.Equal(t, "card", icon)
+
+	b, err := json.Marshal(card)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"string","meta_data":{"group":"billing","icon":"card"}}`, string(b))
+
+	amount, _ := schema.Properties.Get("amount")
+	assert.Nil(t, amount.(*Schema).MetaData)
+}
+
+func TestReflectQueryParams(t *testing.T) {
+	type SearchParams struct {
+		Query    string    `json:"q" jsonschema:"minLength=1"`
+		Page     int       `json:"page,omitempty" jsonschema:"default=1"`
+		Ratio    float64   `json:"ratio,omitempty"`
+		Archived bool      `json:"archived,omitempty"`
+		IDs      []uint    `json:"ids,omitempty"`
+		Sort     string    `json:"sort,omitempty" jsonschema:"enum=asc,enum=desc"`
+		Level    int       `json:"level,omitempty" jsonschema:"enum=1,enum=2"`
+		Since    time.Time `json:"since,omitempty"`
+	}
+
+	r := &Reflector{}
+	schema := r.ReflectQueryParams(&SearchParams{})
+	assert.Nil(t, schema.Definitions)
+	assert.Equal(t, "object", schema.Type)
+
+	get := func(name string) string {
+		p, _ := schema.Properties.Get(name)
+		b, err := json.Marshal(p)
+		require.NoError(t, err)
+		return string(b)
+	}
+	assert.JSONEq(t, `{"type":"string","minLength":1}`, get("q"))
+	assert.JSONEq(t, `{"type":"string","pattern":"^-?[0-9]+$","default":"1"}`, get("page"))
+	assert.JSONEq(t, `{"type":"string","pattern":"^-?[0-9]+(\\.[0-9]+)?$"}`, get("ratio"))
+	assert.JSONEq(t, `{"type":"string","enum":["true","false"]}`, get("archived"))
+	assert.JSONEq(t, `{"type":"array","items":{"type":"string","pattern":"^-?[0-9]+$"}}`, get("ids"))
+	assert.JSONEq(t, `{"type":"string","enum":["asc","desc"]}`, get("sort"))
+	assert.JSONEq(t, `{"type":"string","pattern":"^-?[0-9]+$","enum":["1","2"]}`, get("level"))
+	assert.JSONEq(t, `{"type":"string","format":"date-time"}`, get("since"))
+
+	// the body schema of the same struct is unchanged
+	body := (&Reflector{DoNotReference: true}).Reflect(&SearchParams{})
+	page, _ := body.Properties.Get("page")
+	assert.Equal(t, "integer", page.(*Schema).Type)
+}
+
+func TestNullableSlice(t *testing.T) {
+	type Scores struct {
+		Values []int `json:"values" jsonschema:"nullable,minItems=1"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Scores{})
+
+	values, _ := schema.Properties.Get("values")
+	b, err := json.Marshal(values)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"oneOf": [
+			{"type": "array", "items": {"type": "integer"}, "minItems": 1},
+			{"type": "null"}
+		]
+	}`, string(b))
+}
+
+func TestKeyNamerFull(t *testing.T) {
+	type Filter struct {
+		Since   time.Time `json:"since"`
+		Enabled bool      `json:"enabled" db:"is_enabled"`
+		Name    string
+	}
+
+	r := &Reflector{
+		DoNotReference: true,
+		KeyNamer:       strings.ToUpper,
+		KeyNamerFull: func(f reflect.StructField, name string) string {
+			if col := f.Tag.Get("db"); col != "" {
+				return col
+			}
+			if f.Type == reflect.TypeOf(time.Time{}) {
+				return "ts_" + name
+			}
+			return name
+		},
+	}
+	schema := r.Reflect(&Filter{})
+	assert.Equal(t, []string{"ts_since", "is_enabled", "Name"}, schema.Properties.Keys())
+	assert.Equal(t, []string{"ts_since", "is_enabled", "Name"}, schema.Required)
+
+	// KeyNamer is still used when KeyNamerFull isn't set
+	r.KeyNamerFull = nil
+	schema = r.Reflect(&Filter{})
+	assert.Equal(t, []string{"SINCE", "ENABLED", "NAME"}, schema.Properties.Keys())
+}
+
+func TestReadOnlyWriteOnlyConflict(t *testing.T) {
+	type Credentials struct {
+		Token    string `json:"token" jsonschema:"readOnly=true,writeOnly=true"`
+		Password string `json:"password" jsonschema:"readOnly=true"`
+	}
+
+	r := &Reflector{DoNotReference: true}
+	schema := r.Reflect(&Credentials{})
+	token, _ := schema.Properties.Get("token")
+	assert.True(t, token.(*Schema).ReadOnly)
+	assert.False(t, token.(*Schema).WriteOnly)
+	assert.Equal(t, "writeOnly dropped, conflicts with readOnly", token.(*Schema).Comments)
+
+	// the conflict may also come from WriteOnlyByName
+	r.WriteOnlyByName = []string{"password"}
+	schema = r.Reflect(&Credentials{})
+	password, _ := schema.Properties.Get("password")
+	assert.False(t, password.(*Schema).WriteOnly)
+
+	// a comment set by a tag is kept
+	type Secret struct {
+		Key string `json:"key" jsonschema:"readOnly=true,writeOnly=true,comment=rotated daily"`
+	}
+	schema = (&Reflector{DoNotReference: true}).Reflect(&Secret{})
+	key, _ := schema.Properties.Get("key")
+	assert.Equal(t, "rotated daily; writeOnly dropped, conflicts with readOnly", key.(*Schema).Comments)
+
+	r = &Reflector{StrictTags: true}
+	_, err := r.ReflectSafe(&Credentials{})
+	assert.EqualError(t, err, "Credentials.Token: both readOnly and writeOnly")
+}
+
+func TestTitleFromComment(t *testing.T) {
+	type Invoice struct {
+		Number string `json:"number"`
+	}
+	type Receipt struct {
+		Number string `json:"number"`
+	}
+
+	invoice := fullyQualifiedTypeName(reflect.TypeOf(Invoice{}))
+	receipt := fullyQualifiedTypeName(reflect.TypeOf(Receipt{}))
+	r := &Reflector{
+		TitleFromComment: true,
+		CommentMap: map[string]string{
+			invoice: "Invoice is a bill sent to a customer. It lists v1.2 line items.\nTotals include taxes.",
+			receipt: "Receipt confirms a payment",
+		},
+	}
+	schema := r.Reflect(&Invoice{})
+	def := schema.Definitions["Invoice"]
+	assert.Equal(t, "Invoice is a bill sent to a customer", def.Title)
+	assert.Equal(t, "It lists v1.2 line items.\nTotals include taxes.", def.Description)
+
+	schema = r.Reflect(&Receipt{})
+	assert.Equal(t, "Receipt confirms a payment", schema.Definitions["Receipt"].Title)
+	assert.Empty(t, schema.Definitions["Receipt"].Description)
+
+	// an existing title wins and the whole comment is kept
+	r.TitleFromName = true
+	schema = r.Reflect(&Invoice{})
+	assert.Equal(t, "Invoice", schema.Definitions["Invoice"].Title)
+	assert.Equal(t, r.CommentMap[invoice], schema.Definitions["Invoice"].Description)
+}
+
+type Headers map[string]string
+
+func TestNamedMapDefinition(t *testing.T) {
+	type Exchange struct {
+		Headers
+		Request  Headers `json:"request"`
+		Response Headers `json:"response"`
+	}
+
+	schema := (&Reflector{}).Reflect(&Exchange{})
+	headers := schema.Definitions["Headers"]
+	require.NotNil(t, headers)
+	assert.Equal(t, "object", headers.Type)
+	assert.Equal(t, "string", headers.PatternProperties[".*"].Type)
+
+	// embedded maps are not promoted, they keep their type name as key
+	def := schema.Definitions["Exchange"]
+	for _, name := range []string{"Headers", "request", "response"} {
+		v, ok := def.Properties.Get(name)
+		require.True(t, ok, name)
+		assert.Equal(t, "#/$defs/Headers", v.(*Schema).Ref)
+	}
+}
+
+func TestSelfValidate(t *testing.T) {
+	assert.Empty(t, (&Reflector{}).Reflect(&TestUser{}).SelfValidate())
+	assert.Empty(t, (&Reflector{}).Reflect(&Tree{}).SelfValidate())
+
+	schema := (&Reflector{}).Reflect(&TestUser{})
+	user := schema.Definitions["TestUser"]
+	user.Required = append(user.Required, "missing")
+	friends, _ := user.Properties.Get("friends")
+	friends.(*Schema).Items.Ref = "#/$defs/Ghost"
+	schema.Definitions["Orphan"] = &Schema{Type: "string"}
+
+	assert.Equal(t, []string{
+		"#/$defs/TestUser: required 字段 missing 不在 properties 中",
+		"#/$defs/TestUser/properties/friends/items: $ref #/$defs/Ghost 不存在",
+		"#/$defs/Orphan: 定义未被引用",
+	}, errorStrings(schema.SelfValidate()))
+}
+
+func errorStrings(errs []error) []string {
+	res := make([]string, 0, len(errs))
+	for _, err := range errs {
+		res = append(res, err.Error())
+	}
+	return res
+}
+
+func TestMultiLevelPointers(t *testing.T) {
+	type Indirect struct {
+		Count  **int        `json:"count"`
+		Users  *[]*TestUser `json:"users"`
+		Parent ***TestUser  `json:"parent" jsonschema:"nullable"`
+		Name   **string     `json:"name" jsonschema:"minLength=2"`
+		Tags   **[]**string `json:"tags"`
+	}
+
+	schema := (&Reflector{DoNotReference: true}).Reflect(&Indirect{})
+	count, _ := schema.Properties.Get("count")
+	assert.Equal(t, "integer", count.(*Schema).Type)
+	users, _ := schema.Properties.Get("users")
+	assert.Equal(t, "array", users.(*Schema).Type)
+	assert.Equal(t, "object", users.(*Schema).Items.Type)
+	name, _ := schema.Properties.Get("name")
+	assert.Equal(t, "string", name.(*Schema).Type)
+	assert.Equal(t, 2, name.(*Schema).MinLength)
+	tags, _ := schema.Properties.Get("tags")
+	assert.Equal(t, "string", tags.(*Schema).Items.Type)
+
+	// nullable applies to the outermost level only
+	parent, _ := schema.Properties.Get("parent")
+	require.Len(t, parent.(*Schema).OneOf, 2)
+	assert.Equal(t, "object", parent.(*Schema).OneOf[0].Type)
+	assert.Equal(t, "null", parent.(*Schema).OneOf[1].Type)
+
+	// the root keeps its ID behind any number of pointers
+	user := &TestUser{}
+	assert.Equal(t, (&Reflector{}).Reflect(user).ID, (&Reflector{}).Reflect(&user).ID)
+}
+
+func TestEnumExamples(t *testing.T) {
+	type Order struct {
+		Status   string `json:"status" jsonschema:"enum=open,enum=closed"`
+		Priority int    `json:"priority" jsonschema:"enum=1,enum=2,enum=3,example=2"`
+		Size     string `json:"size" jsonschema:"enum=s,enum=m,enum=l,enum=xl"`
+	}
+
+	schema := (&Reflector{DoNotReference: true}).Reflect(&Order{})
+	status, _ := schema.Properties.Get("status")
+	assert.Empty(t, status.(*Schema).Examples)
+
+	r := &Reflector{DoNotReference: true, EnumExamples: true, MaxEnumValues: 3}
+	schema = r.Reflect(&Order{})
+	status, _ = schema.Properties.Get("status")
+	assert.Equal(t, []interface{}{"open", "closed"}, status.(*Schema).Examples)
+	priority, _ := schema.Properties.Get("priority")
+	assert.Equal(t, []interface{}{2}, priority.(*Schema).Examples)
+	size, _ := schema.Properties.Get("size")
+	assert.Empty(t, size.(*Schema).Examples)
+}
+
+func TestOmitVersion(t *testing.T) {
+	r := &Reflector{OmitVersion: true}
+	for _, schema := range []*Schema{
+		r.Reflect(&TestUser{}),
+		r.ReflectSlice(TestUser{}),
+		r.ReflectMany(TestUser{}, Tree{}),
+	} {
+		assert.Empty(t, schema.Version)
+		data, err := json.Marshal(schema)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), `"$schema"`)
+	}
+
+	assert.Equal(t, Version, (&Reflector{}).Reflect(&TestUser{}).Version)
+}
+
+type ExternalAddress struct{}
+
+func (ExternalAddress) JSONSchema() *Schema {
+	return &Schema{Ref: "https://example.com/schemas/address.json"}
+}
+
+func TestCustomSchemaRefOnly(t *testing.T) {
+	type Shipment struct {
+		From ExternalAddress  `json:"from"`
+		To   *ExternalAddress `json:"to"`
+	}
+
+	schema := (&Reflector{}).Reflect(&Shipment{})
+	assert.NotContains(t, schema.Definitions, "ExternalAddress")
+	def := schema.Definitions["Shipment"]
+	for _, name := range []string{"from", "to"} {
+		v, _ := def.Properties.Get(name)
+		assert.Equal(t, "https://example.com/schemas/address.json", v.(*Schema).Ref)
+	}
+}
+
+func TestUnionTag(t *testing.T) {
+	type Payment struct {
+		ID     string  `json:"id"`
+		Card   *string `json:"card,omitempty" jsonschema:"union=method"`
+		Bank   *string `json:"bank,omitempty" jsonschema:"union=method"`
+		Wallet *string `json:"wallet" jsonschema:"union=method"`
+	}
+
+	schema := (&Reflector{DoNotReference: true}).Reflect(&Payment{})
+	assert.Equal(t, []string{"id"}, schema.Required)
+	require.Len(t, schema.AllOf, 1)
+	assert.Equal(t, "method", schema.AllOf[0].Title)
+	require.Len(t, schema.AllOf[0].OneOf, 3)
+	for i, name := range []string{"card", "bank", "wallet"} {
+		assert.Equal(t, []string{name}, schema.AllOf[0].OneOf[i].Required)
+	}
+	assert.Empty(t, ValidateTag(`union=method`))
+
+	// an unrelated allOf entry titled like the group is left alone
+	titled := &Schema{Title: "method", Required: []string{"id"}}
+	parent := &Schema{AllOf: []*Schema{titled}}
+	(&Schema{}).genericKeywords([]string{"union=method"}, parent, reflect.StructField{}, "card")
+	require.Len(t, parent.AllOf, 2)
+	assert.Empty(t, titled.OneOf)
+	assert.Equal(t, []string{"card"}, parent.AllOf[1].OneOf[0].Required)
+
+	// the tag has no effect when custom tags are ignored
+	schema = (&Reflector{DoNotReference: true, IgnoreCustomTags: true}).Reflect(&Payment{})
+	assert.Equal(t, []string{"id", "wallet"}, schema.Required)
+	assert.Empty(t, schema.AllOf)
+}
+
+func TestInterfaceMapIsOpenObject(t *testing.T) {
+	type Event struct {
+		Attributes map[string]interface{} `json:"attributes"`
+	}
+
+	schema := (&Reflector{DoNotReference: true}).Reflect(&Event{})
+	attributes, _ := schema.Properties.Get("attributes")
+	data, err := json.Marshal(attributes)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"object"}`, string(data))
+	// only the struct itself is closed
+	assert.Equal(t, FalseSchema, schema.AdditionalProperties)
+
+	schema = (&Reflector{}).Reflect(map[string]interface{}{})
+	assert.Nil(t, schema.AdditionalProperties)
+	assert.Empty(t, schema.PatternProperties)
+}
+
+func TestDescriptionResolver(t *testing.T) {
+	type Profile struct {
+		Name  string `json:"name" jsonschema:"desc_key=user.name.desc"`
+		Email string `json:"email" jsonschema:"desc_key=user.email.desc,description=Contact email"`
+		Age   int    `json:"age" jsonschema:"description=Age in years"`
+	}
+
+	messages := map[string]string{
+		"user.name.desc": "用户名",
+	}
+	r := &Reflector{
+		DoNotReference:      true,
+		DescriptionResolver: func(key string) string { return messages[key] },
+	}
+	schema := r.Reflect(&Profile{})
+	name, _ := schema.Properties.Get("name")
+	assert.Equal(t, "用户名", name.(*Schema).Description)
+	email, _ := schema.Properties.Get("email")
+	assert.Equal(t, "Contact email", email.(*Schema).Description)
+	age, _ := schema.Properties.Get("age")
+	assert.Equal(t, "Age in years", age.(*Schema).Description)
+
+	messages["user.email.desc"] = "联系邮箱"
+	schema = r.Reflect(&Profile{})
+	email, _ = schema.Properties.Get("email")
+	assert.Equal(t, "联系邮箱", email.(*Schema).Description)
+
+	// keys and other keywords may hold escaped commas
+	type Address struct {
+		City   string `json:"city" jsonschema:"desc_key=address.city\\,town"`
+		Street string `json:"street" jsonschema:"example=desc_key=x\\,y"`
+	}
+	messages["address.city,town"] = "城市"
+	messages[`x\`] = "wrong"
+	schema = r.Reflect(&Address{})
+	city, _ := schema.Properties.Get("city")
+	assert.Equal(t, "城市", city.(*Schema).Description)
+	street, _ := schema.Properties.Get("street")
+	assert.Empty(t, street.(*Schema).Description)
+}
+
+func TestContentAddressedDefs(t *testing.T) {
+	type Point struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+	type Vector struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+	type Shape struct {
+		Origin    Point   `json:"origin"`
+		Direction Vector  `json:"direction"`
+		Points    []Point `json:"points"`
+	}
+
+	r := &Reflector{ContentAddressedDefs: true}
+	schema := r.Reflect(&Shape{})
+	require.Len(t, schema.Definitions, 2)
+	assert.Regexp(t, `^#/\$defs/def_[0-9a-f]{16}$`, schema.Ref)
+	shape := schema.Definitions[strings.TrimPrefix(schema.Ref, "#/$defs/")]
+	require.NotNil(t, shape)
+	origin, _ := shape.Properties.Get("origin")
+	direction, _ := shape.Properties.Get("direction")
+	points, _ := shape.Properties.Get("points")
+	assert.Equal(t, origin.(*Schema).Ref, direction.(*Schema).Ref)
+	assert.Equal(t, origin.(*Schema).Ref, points.(*Schema).Items.Ref)
+	assert.Empty(t, schema.SelfValidate())
+
+	// names only depend on content
+	again := r.Reflect(&Shape{})
+	assert.Equal(t, schema.Ref, again.Ref)
+
+	tree := r.Reflect(&Tree{})
+	assert.Empty(t, tree.SelfValidate())
+}
+
+func TestReflectPatch(t *testing.T) {
+	type Address struct {
+		City string `json:"city"`
+	}
+	type Account struct {
+		Name    string   `json:"name" jsonschema:"minLength=1"`
+		Age     int      `json:"age"`
+		Tags    []string `json:"tags"`
+		Address Address  `json:"address"`
+		Email   *string  `json:"email,omitempty" jsonschema:"union=contact"`
+		Phone   *string  `json:"phone,omitempty" jsonschema:"union=contact"`
+	}
+
+	schema := (&Reflector{}).ReflectPatch(&Account{})
+	account := schema.Definitions["Account"]
+	assert.Empty(t, account.Required)
+	assert.Empty(t, account.AllOf)
+	assert.Empty(t, schema.Definitions["Address"].Required)
+
+	name, _ := account.Properties.Get("name")
+	require.Len(t, name.(*Schema).OneOf, 2)
+	assert.Equal(t, 1, name.(*Schema).OneOf[0].MinLength)
+	assert.Equal(t, "null", name.(*Schema).OneOf[1].Type)
+	city, _ := schema.Definitions["Address"].Properties.Get("city")
+	assert.Equal(t, "null", city.(*Schema).OneOf[1].Type)
+
+	tags, _ := account.Properties.Get("tags")
+	assert.Equal(t, "array", tags.(*Schema).Type)
+	address, _ := account.Properties.Get("address")
+	assert.Equal(t, "#/$defs/Address", address.(*Schema).Ref)
+
+	schema = (&Reflector{OpenAPINullable: true, DoNotReference: true}).ReflectPatch(&Account{})
+	age, _ := schema.Properties.Get("age")
+	assert.Equal(t, "integer", age.(*Schema).Type)
+	assert.Equal(t, true, age.(*Schema).Extras["nullable"])
+}
+
+func TestOmitemptyStructStaysRequired(t *testing.T) {
+	type Period struct {
+		From time.Time `json:"from"`
+	}
+	type Booking struct {
+		Period   Period     `json:"period,omitempty"`
+		Created  time.Time  `json:"created,omitempty"`
+		Updated  *time.Time `json:"updated,omitempty"`
+		Archived time.Time  `json:"archived,omitempty,omitzero"`
+		Note     string     `json:"note,omitempty"`
+		Paid     time.Time  `json:"paid,omitempty" jsonschema:"oneof_required=paid"`
+	}
+
+	schema := (&Reflector{DoNotReference: true}).Reflect(&Booking{})
+	assert.Equal(t, []string{"period", "created"}, schema.Required)
+
+	data, err := json.Marshal(Booking{})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"period":`)
+	assert.Contains(t, string(data), `"created":`)
+	assert.NotContains(t, string(data), `"updated":`)
+}
+
+func TestAnnotateProvenance(t *testing.T) {
+	type Ledger struct {
+		Owner   TestUser `json:"owner"`
+		Comment string   `json:"comment"`
+	}
+
+	schema := (&Reflector{AnnotateProvenance: true}).Reflect(&Ledger{})
+	assert.Equal(t, "github.com/23233/jsonschema.Ledger", schema.Definitions["Ledger"].Comments)
+	assert.Equal(t, "github.com/23233/jsonschema.TestUser", schema.Definitions["TestUser"].Comments)
+
+	data, err := json.Marshal(schema.Definitions["Ledger"])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"$comment":"github.com/23233/jsonschema.Ledger"`)
+
+	schema = (&Reflector{}).Reflect(&Ledger{})
+	assert.Empty(t, schema.Definitions["Ledger"].Comments)
+}
+
+type Currency struct {
+	code string
+}
+
+func (c Currency) String() string { return c.code }
+
+func (c Currency) MarshalJSON() ([]byte, error) { return json.Marshal(c.String()) }
+
+type Level int
+
+func (l *Level) String() string { return fmt.Sprint(int(*l)) }
+
+func TestStringerAsString(t *testing.T) {
+	type Price struct {
+		Currency Currency     `json:"currency"`
+		Level    Level        `json:"level"`
+		Label    fmt.Stringer `json:"label"`
+		Created  time.Time    `json:"created"`
+	}
+
+	schema := (&Reflector{DoNotReference: true}).Reflect(&Price{})
+	currency, _ := schema.Properties.Get("currency")
+	assert.Equal(t, "object", currency.(*Schema).Type)
+
+	schema = (&Reflector{DoNotReference: true, StringerAsString: true}).Reflect(&Price{})
+	currency, _ = schema.Properties.Get("currency")
+	assert.Equal(t, &Schema{Type: "string"}, currency.(*Schema))
+	level, _ := schema.Properties.Get("level")
+	assert.Equal(t, "string", level.(*Schema).Type)
+	label, _ := schema.Properties.Get("label")
+	assert.Empty(t, label.(*Schema).Type)
+	created, _ := schema.Properties.Get("created")
+	assert.Equal(t, "date-time", created.(*Schema).Format)
+}
+
+func TestBytesHex(t *testing.T) {
+	type Digest struct {
+		Raw    []byte `json:"raw"`
+		SHA256 []byte `json:"sha256" jsonschema:"bytes=hex,minBytes=32,maxBytes=32"`
+		Name   string `json:"name" jsonschema:"bytes=hex"`
+	}
+
+	schema := (&Reflector{DoNotReference: true}).Reflect(&Digest{})
+	raw, _ := schema.Properties.Get("raw")
+	assert.Equal(t, "base64", raw.(*Schema).ContentEncoding)
+
+	sha, _ := schema.Properties.Get("sha256")
+	data, err := json.Marshal(sha)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"string","pattern":"^[0-9a-fA-F]*$","minLength":64,"maxLength":64}`, string(data))
+
+	name, _ := schema.Properties.Get("name")
+	assert.Empty(t, name.(*Schema).Pattern)
+}
+
+func TestReflectEnvelope(t *testing.T) {
+	type Response struct {
+		Data  interface{}            `json:"data"`
+		Error *string                `json:"error,omitempty"`
+		Meta  map[string]interface{} `json:"meta,omitempty"`
+	}
+
+	schema, err := (&Reflector{}).ReflectEnvelope(&TestUser{}, &Response{}, "Data")
+	require.NoError(t, err)
+	assert.Equal(t, "#/$defs/Response", schema.Ref)
+	response := schema.Definitions["Response"]
+	assert.Equal(t, []string{"data"}, response.Required)
+	data, _ := response.Properties.Get("data")
+	assert.Equal(t, "#/$defs/TestUser", data.(*Schema).Ref)
+	assert.NotNil(t, schema.Definitions["TestUser"])
+	assert.Empty(t, schema.SelfValidate())
+
+	schema, err = (&Reflector{DoNotReference: true}).ReflectEnvelope([]Tree{}, &Response{}, "Data")
+	require.NoError(t, err)
+	data, _ = schema.Properties.Get("data")
+	assert.Equal(t, "array", data.(*Schema).Type)
+	assert.Contains(t, schema.Definitions, "Tree")
+	assert.Empty(t, schema.SelfValidate())
+
+	// the slot keeps its renamed key
+	type Result struct {
+		Payload interface{} `json:"result"`
+		Code    int         `json:"code"`
+	}
+	r := &Reflector{DoNotReference: true, KeyNamer: strings.ToUpper}
+	schema, err = r.ReflectEnvelope("", &Result{}, "Payload")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"RESULT", "CODE"}, schema.Properties.Keys())
+	result, _ := schema.Properties.Get("RESULT")
+	assert.Equal(t, "string", result.(*Schema).Type)
+
+	// a missing slot is an error
+	_, err = (&Reflector{}).ReflectEnvelope("", &Result{}, "Data")
+	assert.EqualError(t, err, "envelope jsonschema.Result has no field Data")
+	type Hidden struct {
+		Data interface{} `json:"-"`
+	}
+	_, err = (&Reflector{}).ReflectEnvelope("", &Hidden{}, "Data")
+	assert.EqualError(t, err, "envelope jsonschema.Hidden field Data is not reflected as a property")
+	_, err = (&Reflector{}).ReflectEnvelope("", "", "Data")
+	assert.EqualError(t, err, "expected a struct envelope, got string")
+
+	// the envelope and the payload are reflected as one document
+	type Signed struct {
+		Data      interface{} `json:"data"`
+		Signature []byte      `json:"signature"`
+	}
+	type Blob struct {
+		Content []byte `json:"content"`
+	}
+	schema, err = (&Reflector{SharedByteSchema: true}).ReflectEnvelope(&Blob{}, &Signed{}, "Data")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Signed", "Blob", "Base64Bytes"}, definitionNames(schema.Definitions))
+	assert.Empty(t, schema.SelfValidate())
+
+	schema, err = (&Reflector{ContentAddressedDefs: true}).ReflectEnvelope(&TestUser{}, &Response{}, "Data")
+	require.NoError(t, err)
+	for _, name := range definitionNames(schema.Definitions) {
+		assert.True(t, strings.HasPrefix(name, "def_"), name)
+	}
+	assert.Empty(t, schema.SelfValidate())
+}
+
+func TestNonEmptyRequiredSlices(t *testing.T) {
+	type Batch struct {
+		Items    []string  `json:"items"`
+		Optional []string  `json:"optional,omitempty"`
+		Pair     []int     `json:"pair" jsonschema:"minItems=2"`
+		Raw      []byte    `json:"raw"`
+		Fixed    [3]int    `json:"fixed"`
+		Pointer  *[]string `json:"pointer"`
+	}
+
+	schema := (&Reflector{DoNotReference: true}).Reflect(&Batch{})
+	items, _ := schema.Properties.Get("items")
+	assert.Equal(t, 0, items.(*Schema).MinItems)
+
+	schema = (&Reflector{DoNotReference: true, NonEmptyRequiredSlices: true}).Reflect(&Batch{})
+	items, _ = schema.Properties.Get("items")
+	assert.Equal(t, 1, items.(*Schema).MinItems)
+	optional, _ := schema.Properties.Get("optional")
+	assert.Equal(t, 0, optional.(*Schema).MinItems)
+	pair, _ := schema.Properties.Get("pair")
+	assert.Equal(t, 2, pair.(*Schema).MinItems)
+	raw, _ := schema.Properties.Get("raw")
+	assert.Equal(t, 0, raw.(*Schema).MinItems)
+	fixed, _ := schema.Properties.Get("fixed")
+	assert.Equal(t, 3, fixed.(*Schema).MinItems)
+	pointer, _ := schema.Properties.Get("pointer")
+	assert.Equal(t, 0, pointer.(*Schema).MinItems)
+}
+
+func TestSharedByteSchema(t *testing.T) {
+	type Attachment struct {
+		Content   []byte   `json:"content"`
+		Thumbnail []byte   `json:"thumbnail"`
+		Pages     [][]byte `json:"pages"`
+	}
+
+	schema := (&Reflector{SharedByteSchema: true}).Reflect(&Attachment{})
+	require.Len(t, schema.Definitions, 2)
+	assert.Equal(t, &Schema{Type: "string", ContentEncoding: "base64"}, schema.Definitions["Base64Bytes"])
+	attachment := schema.Definitions["Attachment"]
+	for _, name := range []string{"content", "thumbnail"} {
+		v, _ := attachment.Properties.Get(name)
+		assert.Equal(t, "#/$defs/Base64Bytes", v.(*Schema).Ref)
+	}
+	pages, _ := attachment.Properties.Get("pages")
+	assert.Equal(t, "#/$defs/Base64Bytes", pages.(*Schema).Items.Ref)
+
+	schema = (&Reflector{}).Reflect(&Attachment{})
+	assert.NotContains(t, schema.Definitions, "Base64Bytes")
+
+	// the definition goes through the same options and hooks as the others
+	var hooked []string
+	r := &Reflector{
+		SharedByteSchema:   true,
+		TitleFromName:      true,
+		AnnotateProvenance: true,
+		OnDefinition:       func(name string, _ *Schema, _ reflect.Type) { hooked = append(hooked, name) },
+	}
+	schema = r.Reflect(&Attachment{})
+	bytes := schema.Definitions["Base64Bytes"]
+	assert.Equal(t, humanizeName("Base64Bytes"), bytes.Title)
+	assert.Equal(t, "[]uint8", bytes.Comments)
+	assert.ElementsMatch(t, []string{"Base64Bytes", "Attachment"}, hooked)
+
+	// a type of the same name is never replaced
+	type Base64Bytes struct {
+		Encoded string `json:"encoded"`
+	}
+	type Before struct {
+		Own  Base64Bytes `json:"own"`
+		Data []byte      `json:"data"`
+	}
+	type After struct {
+		Data []byte      `json:"data"`
+		Own  Base64Bytes `json:"own"`
+	}
+	for _, v := range []interface{}{&Before{}, &After{}} {
+		schema = (&Reflector{SharedByteSchema: true}).Reflect(v)
+		require.Len(t, schema.Definitions, 3)
+		def := schema.Definitions[strings.TrimPrefix(schema.Ref, "#/$defs/")]
+		own, _ := def.Properties.Get("own")
+		data, _ := def.Properties.Get("data")
+		assert.NotEqual(t, own.(*Schema).Ref, data.(*Schema).Ref)
+		ownDef := schema.Definitions[strings.TrimPrefix(own.(*Schema).Ref, "#/$defs/")]
+		dataDef := schema.Definitions[strings.TrimPrefix(data.(*Schema).Ref, "#/$defs/")]
+		assert.Equal(t, []string{"encoded"}, ownDef.Properties.Keys())
+		assert.Equal(t, "base64", dataDef.ContentEncoding)
+	}
+}
+
+func TestSetsAsArrays(t *testing.T) {
+	type Permissions struct {
+		Roles   map[string]struct{} `json:"roles"`
+		Numbers map[int]struct{}    `json:"numbers"`
+		Counts  map[string]int      `json:"counts"`
+	}
+
+	schema := (&Reflector{DoNotReference: true}).Reflect(&Permissions{})
+	roles, _ := schema.Properties.Get("roles")
+	assert.Equal(t, "object", roles.(*Schema).Type)
+
+	schema = (&Reflector{DoNotReference: true, SetsAsArrays: true}).Reflect(&Permissions{})
+	roles, _ = schema.Properties.Get("roles")
+	data, err := json.Marshal(roles)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"array","items":{"type":"string"},"uniqueItems":true}`, string(data))
+	numbers, _ := schema.Properties.Get("numbers")
+	assert.Equal(t, "integer", numbers.(*Schema).Items.Type)
+	counts, _ := schema.Properties.Get("counts")
+	assert.Equal(t, "object", counts.(*Schema).Type)
+}
+
+func TestAddExampleAndEnum(t *testing.T) {
+	s := NewSchema("string").
+		AddEnum("draft", "published").
+		AddEnum("published", "archived").
+		AddExample("draft").
+		AddExample("archived")
+	assert.Equal(t, []interface{}{"draft", "published", "archived"}, s.Enum)
+	assert.Equal(t, []interface{}{"draft", "archived"}, s.Examples)
+
+	data, err := json.Marshal(NewSchema("integer").AddEnum(1, 2).AddExample(2))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"integer","enum":[1,2],"examples":[2]}`, string(data))
+}
+
+func TestTypeRoundTripReused(t *testing.T) {
+	s := new(Schema)
+	require.NoError(t, json.Unmarshal([]byte(`{"type":["string","null"]}`), s))
+	require.NoError(t, json.Unmarshal([]byte(`{"type":["integer","null"]}`), s))
+	assert.Equal(t, []string{"integer", "null"}, s.Types)
+	assert.Empty(t, s.Type)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"string"}`), s))
+	assert.Equal(t, "string", s.Type)
+	assert.Empty(t, s.Types)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"type":["boolean","null"]}`), s))
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	assert.Equal(t, `{"type":["boolean","null"]}`, string(b))
+
+	// a single "type" is written whichever fields hold one
+	for _, s := range []*Schema{
+		{Type: "string", Types: []string{"string", "null"}},
+		{Type: "string", Extras: map[string]interface{}{"type": "object"}},
+		{Types: []string{"string", "null"}, Extras: map[string]interface{}{"type": "object"}},
+	} {
+		b, err := json.Marshal(s)
+		require.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(string(b), `"type"`), string(b))
+		var buf strings.Builder
+		require.NoError(t, s.WriteJSON(&buf))
+		assert.Equal(t, string(b), buf.String())
+	}
+}
@@ -1,6 +1,16 @@
 package jsonschema
 
-import "github.com/iancoleman/orderedmap"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iancoleman/orderedmap"
+)
 
 func NewSchema(types ...string) *Schema {
 	typeName := "string"
@@ -17,6 +27,38 @@ func NewSchema(types ...string) *Schema {
 	return schema
 }
 
+// ToolSchema 将参数schema包装为 LLM tool calling 所需的 {"name","description","parameters"} 结构
+// 会移除这些接口不接受的 $schema 与 $id 对象类型默认补充 additionalProperties:false
+// 参数schema建议使用 ExpandedStruct 或 ReflectFunc 生成 避免根节点是 $ref
+// 参数schema无法序列化时返回错误
+func ToolSchema(name, description string, params *Schema) (map[string]any, error) {
+	parameters := map[string]any{
+		"type":       "object",
+		"properties": map[string]any{},
+	}
+	if params != nil {
+		m, err := StructToMap(params)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: %w", name, err)
+		}
+		parameters = m
+	}
+	delete(parameters, "$schema")
+	delete(parameters, "$id")
+	if _, ok := parameters["additionalProperties"]; !ok && parameters["type"] == "object" {
+		parameters["additionalProperties"] = false
+	}
+
+	tool := map[string]any{
+		"name":       name,
+		"parameters": parameters,
+	}
+	if description != "" {
+		tool["description"] = description
+	}
+	return tool, nil
+}
+
 func NewSchemaSetItems(typeName string) *Schema {
 	var schema = NewSchema("array")
 	schema.Items = NewSchema(typeName)
@@ -43,6 +85,22 @@ func (t *Schema) IsNormal() bool {
 	return !t.IsObj() && !t.IsArray() && !t.IsNull()
 }
 
+// SetNot 设置 not 关键词 可在 JSONSchemaExtend 中使用
+// 标签 not_const= 只能表达 not:{const:x} 这种简单的取反 复杂的取反需要通过此方法设置
+func (t *Schema) SetNot(not *Schema) {
+	t.Not = not
+}
+
+// AddDependentSchema 当对象中存在 property 时 对象还必须满足 s
+// 与 dependentRequired 不同 dependentRequired 只能要求其他字段必填 而 dependentSchemas 可以施加任意约束
+// 可在 JSONSchemaExtend 中使用
+func (t *Schema) AddDependentSchema(property string, s *Schema) {
+	if t.DependentSchemas == nil {
+		t.DependentSchemas = make(map[string]*Schema)
+	}
+	t.DependentSchemas[property] = s
+}
+
 func (t *Schema) AddMeta(key string, value interface{}) {
 	if t.MetaData == nil {
 		t.MetaData = make(map[string]interface{})
@@ -50,6 +108,29 @@ func (t *Schema) AddMeta(key string, value interface{}) {
 	t.MetaData[key] = value
 }
 
+// AddExample 追加一个示例值 返回自身以便链式调用
+func (t *Schema) AddExample(v interface{}) *Schema {
+	t.Examples = append(t.Examples, v)
+	return t
+}
+
+// AddEnum 追加枚举值 已存在的值会被跳过 返回自身以便链式调用
+func (t *Schema) AddEnum(values ...interface{}) *Schema {
+	for _, v := range values {
+		exists := false
+		for _, e := range t.Enum {
+			if reflect.DeepEqual(e, v) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			t.Enum = append(t.Enum, v)
+		}
+	}
+	return t
+}
+
 func (t *Schema) GetMeta(key string) (interface{}, bool) {
 	if t.MetaData == nil {
 		return nil, false
@@ -57,3 +138,474 @@ func (t *Schema) GetMeta(key string) (interface{}, bool) {
 	v, ok := t.MetaData[key]
 	return v, ok
 }
+
+// Equal 结构化比较两个schema 通过json序列化消除 Extras map顺序 properties顺序 以及 nil与空切片 的差异
+// 数组(如 enum required)的顺序仍然有意义
+func (t *Schema) Equal(other *Schema) bool {
+	if t == nil || other == nil {
+		return t == other
+	}
+	a, err := t.normalized()
+	if err != nil {
+		return false
+	}
+	b, err := other.normalized()
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func (t *Schema) normalized() (interface{}, error) {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	err = json.Unmarshal(b, &v)
+	return v, err
+}
+
+// subSchemas 返回当前schema直接包含的所有子schema
+func (t *Schema) subSchemas() []*Schema {
+	var res []*Schema
+	add := func(list ...*Schema) {
+		for _, s := range list {
+			if s != nil {
+				res = append(res, s)
+			}
+		}
+	}
+	add(t.Not, t.If, t.Then, t.Else, t.Items, t.Contains, t.AdditionalProperties, t.PropertyNames, t.ContentSchema)
+	add(t.AllOf...)
+	add(t.AnyOf...)
+	add(t.OneOf...)
+	add(t.PrefixItems...)
+	for _, s := range t.Definitions {
+		add(s)
+	}
+	for _, s := range t.DependentSchemas {
+		add(s)
+	}
+	for _, s := range t.PatternProperties {
+		add(s)
+	}
+	if t.Properties != nil {
+		for _, key := range t.Properties.Keys() {
+			v, _ := t.Properties.Get(key)
+			if s, ok := v.(*Schema); ok {
+				add(s)
+			}
+		}
+	}
+	return res
+}
+
+// Simplify 递归地将只有一个元素的 oneOf/anyOf 合并到当前schema中 并移除空的 oneOf/anyOf
+// 如果元素与当前schema存在冲突的关键词 则保持不变
+func (t *Schema) Simplify() {
+	if t == nil || t.boolean != nil {
+		return
+	}
+	for _, s := range t.subSchemas() {
+		s.Simplify()
+	}
+
+	if len(t.OneOf) == 0 {
+		t.OneOf = nil
+	}
+	if len(t.AnyOf) == 0 {
+		t.AnyOf = nil
+	}
+	if len(t.OneOf) == 1 {
+		t.foldCombinator("OneOf", t.OneOf[0])
+	}
+	if len(t.AnyOf) == 1 {
+		t.foldCombinator("AnyOf", t.AnyOf[0])
+	}
+}
+
+// foldCombinator 将 combinator 字段中唯一的元素合并到当前schema 只有在没有冲突时才会修改
+func (t *Schema) foldCombinator(combinator string, elem *Schema) bool {
+	dst := reflect.ValueOf(t).Elem()
+	src := reflect.ValueOf(elem).Elem()
+	combinatorField := dst.FieldByName(combinator)
+
+	if elem.boolean != nil {
+		if *elem.boolean {
+			combinatorField.Set(reflect.Zero(combinatorField.Type()))
+			return true
+		}
+		return false
+	}
+
+	typ := dst.Type()
+	// 先检查冲突 被合并的 combinator 字段本身除外
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).PkgPath != "" || typ.Field(i).Name == combinator {
+			continue
+		}
+		df, sf := dst.Field(i), src.Field(i)
+		if sf.IsZero() || df.IsZero() {
+			continue
+		}
+		if typ.Field(i).Name == "Extras" {
+			for k, v := range elem.Extras {
+				if existing, ok := t.Extras[k]; ok && !reflect.DeepEqual(existing, v) {
+					return false
+				}
+			}
+			continue
+		}
+		if !reflect.DeepEqual(df.Interface(), sf.Interface()) {
+			return false
+		}
+	}
+
+	combinatorField.Set(reflect.Zero(combinatorField.Type()))
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).PkgPath != "" {
+			continue
+		}
+		df, sf := dst.Field(i), src.Field(i)
+		if sf.IsZero() {
+			continue
+		}
+		if typ.Field(i).Name == "Extras" {
+			for k, v := range elem.Extras {
+				if t.Extras == nil {
+					t.Extras = make(map[string]interface{})
+				}
+				t.Extras[k] = v
+			}
+			continue
+		}
+		if df.IsZero() {
+			df.Set(sf)
+		}
+	}
+	return true
+}
+
+// clone 深拷贝schema树 子schema与properties会被复制 其他切片与map仍与原schema共享
+func (t *Schema) clone() *Schema {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	v := reflect.ValueOf(&c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		fv := v.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+		switch {
+		case fv.Type() == schemaType:
+			fv.Set(reflect.ValueOf(fv.Interface().(*Schema).clone()))
+		case fv.Type() == schemaSliceType && !fv.IsNil():
+			list := make([]*Schema, fv.Len())
+			for j := range list {
+				list[j] = fv.Index(j).Interface().(*Schema).clone()
+			}
+			fv.Set(reflect.ValueOf(list))
+		case fv.Type() == orderedMapType && !fv.IsNil():
+			om := fv.Interface().(*orderedmap.OrderedMap)
+			props := orderedmap.New()
+			for _, k := range om.Keys() {
+				val, _ := om.Get(k)
+				if s, ok := val.(*Schema); ok {
+					val = s.clone()
+				}
+				props.Set(k, val)
+			}
+			fv.Set(reflect.ValueOf(props))
+		case fv.Kind() == reflect.Map && fv.Type().Elem() == schemaType && !fv.IsNil():
+			m := reflect.MakeMapWithSize(fv.Type(), fv.Len())
+			iter := fv.MapRange()
+			for iter.Next() {
+				m.SetMapIndex(iter.Key(), reflect.ValueOf(iter.Value().Interface().(*Schema).clone()))
+			}
+			fv.Set(m)
+		}
+	}
+	return &c
+}
+
+// Prune 返回一个移除了不满足 keep 的属性的副本 被移除的属性也会从 required 中移除
+// path 为以 . 连接的属性名 数组元素使用 * 表示 例如 items.*.field
+// $defs 中的定义以 $defs.定义名 开头 例如 $defs.User.password
+func (t *Schema) Prune(keep func(path string, s *Schema) bool) *Schema {
+	c := t.clone()
+	c.prune("", keep)
+	return c
+}
+
+func (t *Schema) prune(path string, keep func(path string, s *Schema) bool) {
+	if t == nil {
+		return
+	}
+	joinPath := func(name string) string {
+		if path == "" {
+			return name
+		}
+		return path + "." + name
+	}
+
+	if t.Properties != nil {
+		for _, key := range append([]string(nil), t.Properties.Keys()...) {
+			v, _ := t.Properties.Get(key)
+			s, ok := v.(*Schema)
+			if !ok {
+				continue
+			}
+			if !keep(joinPath(key), s) {
+				t.Properties.Delete(key)
+				t.Required = removeString(t.Required, key)
+				continue
+			}
+			s.prune(joinPath(key), keep)
+		}
+	}
+	t.Items.prune(joinPath("*"), keep)
+	for i, s := range t.PrefixItems {
+		s.prune(joinPath(strconv.Itoa(i)), keep)
+	}
+	for _, list := range [][]*Schema{t.AllOf, t.AnyOf, t.OneOf} {
+		for _, s := range list {
+			s.prune(path, keep)
+		}
+	}
+	for name, s := range t.Definitions {
+		s.prune("$defs."+name, keep)
+	}
+}
+
+func removeString(list []string, value string) []string {
+	res := make([]string, 0, len(list))
+	for _, s := range list {
+		if s != value {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// FlattenAllOf 将 allOf 中的所有成员与当前schema合并为一个等价的schema 返回合并后的副本
+// properties 与 required 取并集 min/max 类约束取更严格的值 enum 取交集
+// title description 等注释类关键词保留已存在的值 其他关键词存在不同的值时返回错误
+// 成员中的 $ref 无法合并 需要先内联
+func (t *Schema) FlattenAllOf() (*Schema, error) {
+	res := t.clone()
+	members := res.AllOf
+	res.AllOf = nil
+	for _, member := range members {
+		if len(member.AllOf) > 0 {
+			flat, err := member.FlattenAllOf()
+			if err != nil {
+				return nil, err
+			}
+			member = flat
+		}
+		if err := res.mergeSchema(member); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// mergeSchema 将 elem 合并到当前schema 规则见 FlattenAllOf
+func (t *Schema) mergeSchema(elem *Schema) error {
+	if elem.boolean != nil {
+		if *elem.boolean {
+			return nil
+		}
+		return errors.New("false schema 无法合并")
+	}
+	if elem.Ref != "" {
+		return fmt.Errorf("$ref %s 需要先内联才能合并", elem.Ref)
+	}
+
+	dst := reflect.ValueOf(t).Elem()
+	src := reflect.ValueOf(elem).Elem()
+	typ := dst.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		name := typ.Field(i).Name
+		if typ.Field(i).PkgPath != "" {
+			continue
+		}
+		df, sf := dst.Field(i), src.Field(i)
+		if sf.IsZero() {
+			continue
+		}
+		switch name {
+		case "Properties":
+			if t.Properties == nil {
+				t.Properties = orderedmap.New()
+			}
+			for _, key := range elem.Properties.Keys() {
+				v, _ := elem.Properties.Get(key)
+				prop, ok := v.(*Schema)
+				if !ok {
+					continue
+				}
+				if existing, ok := t.Properties.Get(key); ok {
+					if err := existing.(*Schema).mergeSchema(prop); err != nil {
+						return fmt.Errorf("属性 %s: %w", key, err)
+					}
+					continue
+				}
+				t.Properties.Set(key, prop.clone())
+			}
+		case "Required":
+			// 副本与原schema共享切片 先复制再追加
+			t.Required = append([]string(nil), t.Required...)
+			for _, r := range elem.Required {
+				t.Required = appendUniqueString(t.Required, r)
+			}
+		case "MinLength", "Minimum", "MinItems", "MinProperties":
+			if sf.Int() > df.Int() {
+				df.Set(sf)
+			}
+		case "MaxLength", "Maximum", "MaxItems", "MaxProperties":
+			if df.IsZero() || sf.Int() < df.Int() {
+				df.Set(sf)
+			}
+		case "Enum":
+			if len(t.Enum) == 0 {
+				t.Enum = elem.Enum
+				continue
+			}
+			var both []interface{}
+			for _, a := range t.Enum {
+				for _, b := range elem.Enum {
+					if reflect.DeepEqual(a, b) {
+						both = append(both, a)
+						break
+					}
+				}
+			}
+			if len(both) == 0 {
+				return errors.New("enum 没有共同的值")
+			}
+			t.Enum = both
+		case "AdditionalProperties", "Items":
+			s := sf.Interface().(*Schema)
+			switch existing := df.Interface().(*Schema); {
+			case existing == nil:
+				df.Set(reflect.ValueOf(s.clone()))
+			case existing.Equal(FalseSchema) || s.Equal(FalseSchema):
+				df.Set(reflect.ValueOf(FalseSchema))
+			default:
+				if err := existing.mergeSchema(s); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+		case "Extras", "MetaData":
+			m := make(map[string]interface{})
+			for k, v := range df.Interface().(map[string]interface{}) {
+				m[k] = v
+			}
+			df.Set(reflect.ValueOf(m))
+			for k, v := range sf.Interface().(map[string]interface{}) {
+				if existing, ok := m[k]; ok && !reflect.DeepEqual(existing, v) {
+					return fmt.Errorf("关键词 %s 存在冲突", k)
+				}
+				m[k] = v
+			}
+		case "Title", "Description", "Comments", "Examples", "Default":
+			if df.IsZero() {
+				df.Set(sf)
+			}
+		default:
+			if df.IsZero() {
+				df.Set(sf)
+			} else if !reflect.DeepEqual(df.Interface(), sf.Interface()) {
+				return fmt.Errorf("关键词 %s 存在冲突", name)
+			}
+		}
+	}
+	return nil
+}
+
+// SelfValidate 检查schema的内部一致性 返回发现的所有问题
+// 所有 #/$defs/ 引用都必须存在 除根之外的定义都必须被引用 required 中的字段都必须存在于 properties
+// 只有在设置了 properties 时才会检查 required 以允许 oneOf 等只包含 required 的子schema
+func (t *Schema) SelfValidate() []error {
+	var errs []error
+	root := *t
+	root.Definitions = nil
+	used := referencedDefinitions(&root, t.Definitions)
+
+	t.selfValidate("#", t.Definitions, &errs)
+	for _, name := range sortedKeys(t.Definitions) {
+		if _, ok := used[name]; !ok {
+			errs = append(errs, fmt.Errorf("#/$defs/%s: 定义未被引用", name))
+		}
+	}
+	return errs
+}
+
+func (t *Schema) selfValidate(path string, definitions Definitions, errs *[]error) {
+	if t == nil {
+		return
+	}
+	if name := strings.TrimPrefix(t.Ref, "#/$defs/"); name != t.Ref && definitions[name] == nil {
+		*errs = append(*errs, fmt.Errorf("%s: $ref %s 不存在", path, t.Ref))
+	}
+	if t.Properties != nil {
+		for _, name := range t.Required {
+			if _, ok := t.Properties.Get(name); !ok {
+				*errs = append(*errs, fmt.Errorf("%s: required 字段 %s 不在 properties 中", path, name))
+			}
+		}
+		for _, key := range t.Properties.Keys() {
+			v, _ := t.Properties.Get(key)
+			if s, ok := v.(*Schema); ok {
+				s.selfValidate(path+"/properties/"+escapePointer(key), definitions, errs)
+			}
+		}
+	}
+
+	children := []struct {
+		key string
+		s   *Schema
+	}{
+		{"not", t.Not}, {"if", t.If}, {"then", t.Then}, {"else", t.Else}, {"items", t.Items}, {"contains", t.Contains},
+		{"additionalProperties", t.AdditionalProperties}, {"propertyNames", t.PropertyNames}, {"contentSchema", t.ContentSchema},
+	}
+	for _, c := range children {
+		c.s.selfValidate(path+"/"+c.key, definitions, errs)
+	}
+	lists := []struct {
+		key  string
+		list []*Schema
+	}{{"allOf", t.AllOf}, {"anyOf", t.AnyOf}, {"oneOf", t.OneOf}, {"prefixItems", t.PrefixItems}}
+	for _, l := range lists {
+		for i, s := range l.list {
+			s.selfValidate(path+"/"+l.key+"/"+strconv.Itoa(i), definitions, errs)
+		}
+	}
+	maps := []struct {
+		key string
+		m   map[string]*Schema
+	}{{"$defs", t.Definitions}, {"dependentSchemas", t.DependentSchemas}, {"patternProperties", t.PatternProperties}}
+	for _, m := range maps {
+		for _, name := range sortedKeys(m.m) {
+			m.m[name].selfValidate(path+"/"+m.key+"/"+escapePointer(name), definitions, errs)
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// escapePointer 按照 JSON Pointer 的规则转义 ~ 与 /
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,202 @@
+package jsonschema
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/iancoleman/orderedmap"
+)
+
+var (
+	schemaType      = reflect.TypeOf(&Schema{})
+	orderedMapType  = reflect.TypeOf(&orderedmap.OrderedMap{})
+	schemaSliceType = reflect.TypeOf([]*Schema{})
+)
+
+// WriteJSON streams the schema as JSON to the provided writer, producing the
+// same output as MarshalJSON without holding the whole document in memory.
+// Sub-schemas are written one at a time as the tree is walked.
+func (t *Schema) WriteJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := t.writeJSON(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// AsJSONReader provides the schema's JSON as a reader, ready to be loaded as a
+// resource by external validators. With santhosh-tekuri/jsonschema for example:
+//
+//	compiler := jsonschema.NewCompiler()
+//	_ = compiler.AddResource(s.ID.String(), s.AsJSONReader())
+//	validator, err := compiler.Compile(s.ID.String())
+//
+// Generated schemas keep their `$defs` in the root document and reference them
+// with local `#/$defs/...` pointers, so they resolve against the resource's `$id`.
+// Any marshaling error is returned by the reader's Read method.
+func (t *Schema) AsJSONReader() io.Reader {
+	var buf bytes.Buffer
+	if err := t.WriteJSON(&buf); err != nil {
+		return errReader{err}
+	}
+	return &buf
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func (t *Schema) writeJSON(w *bufio.Writer) error {
+	if t == nil {
+		_, err := w.WriteString("null")
+		return err
+	}
+	if t.boolean != nil || reflect.DeepEqual(&Schema{}, t) {
+		b, err := t.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+
+	w.WriteByte('{')
+	first := true
+	writeKey := func(key string) error {
+		if !first {
+			w.WriteByte(',')
+		}
+		first = false
+		return writeJSONValue(w, key)
+	}
+
+	v := reflect.ValueOf(t).Elem()
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if name == "-" || name == "" || name == "type" && len(t.Types) > 0 {
+			continue
+		}
+		fv := v.Field(i)
+		switch fv.Kind() {
+		case reflect.Slice, reflect.Map:
+			if fv.Len() == 0 {
+				continue
+			}
+		default:
+			if fv.IsZero() {
+				continue
+			}
+		}
+		if err := writeKey(name); err != nil {
+			return err
+		}
+		if err := writeSchemaField(w, fv); err != nil {
+			return err
+		}
+	}
+
+	extras := t.marshalExtras()
+	keys := make([]string, 0, len(extras))
+	for k := range extras {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := writeKey(k); err != nil {
+			return err
+		}
+		w.WriteByte(':')
+		if err := writeJSONValue(w, extras[k]); err != nil {
+			return err
+		}
+	}
+
+	return w.WriteByte('}')
+}
+
+// writeSchemaField writes the ':' separator and the value of a Schema field,
+// recursing into sub-schemas instead of marshaling them as a whole.
+func writeSchemaField(w *bufio.Writer, fv reflect.Value) error {
+	w.WriteByte(':')
+	switch {
+	case fv.Type() == schemaType:
+		return fv.Interface().(*Schema).writeJSON(w)
+	case fv.Type() == schemaSliceType:
+		w.WriteByte('[')
+		for i, s := range fv.Interface().([]*Schema) {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := s.writeJSON(w); err != nil {
+				return err
+			}
+		}
+		return w.WriteByte(']')
+	case fv.Type() == orderedMapType:
+		om := fv.Interface().(*orderedmap.OrderedMap)
+		w.WriteByte('{')
+		for i, k := range om.Keys() {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := writeJSONValue(w, k); err != nil {
+				return err
+			}
+			w.WriteByte(':')
+			val, _ := om.Get(k)
+			if s, ok := val.(*Schema); ok {
+				if err := s.writeJSON(w); err != nil {
+					return err
+				}
+			} else if err := writeJSONValue(w, val); err != nil {
+				return err
+			}
+		}
+		return w.WriteByte('}')
+	case fv.Kind() == reflect.Map && fv.Type().Elem() == schemaType:
+		keys := make([]string, 0, fv.Len())
+		for _, k := range fv.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		w.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := writeJSONValue(w, k); err != nil {
+				return err
+			}
+			w.WriteByte(':')
+			s := fv.MapIndex(reflect.ValueOf(k).Convert(fv.Type().Key())).Interface().(*Schema)
+			if err := s.writeJSON(w); err != nil {
+				return err
+			}
+		}
+		return w.WriteByte('}')
+	}
+	return writeJSONValue(w, fv.Interface())
+}
+
+func writeJSONValue(w *bufio.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,269 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// stringFormats lists the formats accepted by the `format` tag keyword on strings.
+var stringFormats = map[string]bool{
+	"date-time":     true,
+	"email":         true,
+	"hostname":      true,
+	"ipv4":          true,
+	"ipv6":          true,
+	"uri":           true,
+	"uuid":          true,
+	"uri-reference": true,
+	"iri":           true,
+	"iri-reference": true,
+}
+
+type tagValueKind int
+
+const (
+	tagFlag tagValueKind = iota
+	tagString
+	tagInt
+	tagBool
+	tagJSON
+	tagPattern
+	tagFormat
+)
+
+// tagKeywords lists every keyword understood in `jsonschema` tags with the kind
+// of value it expects.
+var tagKeywords = map[string]tagValueKind{
+	"-":             tagFlag,
+	"required":      tagFlag,
+	"nullable":      tagFlag,
+	"discriminator": tagFlag,
+
+	"title":                tagString,
+	"description":          tagString,
+	"desc_key":             tagString,
+	"comment":              tagString,
+	"widget":               tagString,
+	"type":                 tagString,
+	"anchor":               tagString,
+	"anchor_ref":           tagString,
+	"oneof_required":       tagString,
+	"anyof_required":       tagString,
+	"union":                tagString,
+	"oneof_type":           tagString,
+	"anyof_type":           tagString,
+	"not_const":            tagString,
+	"enum":                 tagString,
+	"contentMediaType":     tagString,
+	"bytes":                tagString,
+	"default":              tagString,
+	"example":              tagString,
+	"examples":             tagString,
+	"profiles":             tagString,
+	"enum_titled":          tagString,
+	"enum_json":            tagJSON,
+	"pattern":              tagPattern,
+	"format":               tagFormat,
+	"additionalProperties": tagBool,
+	"readOnly":             tagBool,
+	"writeOnly":            tagBool,
+	"exclusiveMaximum":     tagBool,
+	"exclusiveMinimum":     tagBool,
+	"uniqueItems":          tagBool,
+	"minLength":            tagInt,
+	"maxLength":            tagInt,
+	"minBytes":             tagInt,
+	"maxBytes":             tagInt,
+	"multipleOf":           tagInt,
+	"minimum":              tagInt,
+	"maximum":              tagInt,
+	"minProperties":        tagInt,
+	"maxProperties":        tagInt,
+	"minItems":             tagInt,
+	"maxItems":             tagInt,
+}
+
+// repeatableTagKeywords may appear several times in the same tag.
+var repeatableTagKeywords = map[string]bool{
+	"enum":      true,
+	"enum_json": true,
+	"default":   true,
+	"example":   true,
+	"examples":  true,
+}
+
+// ValidateTag checks the content of a `jsonschema` struct tag, reporting unknown
+// keywords, malformed values and duplicated keywords that would otherwise be
+// silently ignored while reflecting. It is intended to be run from unit tests:
+//
+//	for i := 0; i < t.NumField(); i++ {
+//		for _, err := range jsonschema.ValidateTag(t.Field(i).Tag.Get("jsonschema")) {
+//			...
+//		}
+//	}
+func ValidateTag(tag string) []error {
+	if tag == "" {
+		return nil
+	}
+	var errs []error
+	seen := map[string]bool{}
+	for _, item := range splitOnUnescapedCommas(tag) {
+		nameValue := strings.SplitN(item, "=", 2)
+		name := nameValue[0]
+		kind, ok := tagKeywords[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown keyword %q", name))
+			continue
+		}
+		if seen[name] && !repeatableTagKeywords[name] {
+			errs = append(errs, fmt.Errorf("duplicate keyword %q", name))
+		}
+		seen[name] = true
+
+		if kind == tagFlag {
+			if len(nameValue) == 2 {
+				errs = append(errs, fmt.Errorf("keyword %q does not take a value", name))
+			}
+			continue
+		}
+		if len(nameValue) != 2 {
+			errs = append(errs, fmt.Errorf("keyword %q requires a value", name))
+			continue
+		}
+		if err := validateTagValue(kind, nameValue[1]); err != nil {
+			errs = append(errs, fmt.Errorf("keyword %q: %w", name, err))
+		}
+	}
+	return errs
+}
+
+func validateTagValue(kind tagValueKind, val string) error {
+	switch kind {
+	case tagInt:
+		_, err := strconv.Atoi(val)
+		return err
+	case tagBool:
+		_, err := strconv.ParseBool(val)
+		return err
+	case tagJSON:
+		var v interface{}
+		return json.Unmarshal([]byte(val), &v)
+	case tagPattern:
+		_, err := regexp.Compile(val)
+		return err
+	case tagFormat:
+		if !stringFormats[val] {
+			return fmt.Errorf("unsupported format %q", val)
+		}
+	}
+	return nil
+}
+
+// TagErrors lists the problems found in struct tags by ReflectSafe with StrictTags.
+type TagErrors []error
+
+func (e TagErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// typedTagKeywords lists the keywords only applied to schemas of a given type.
+var typedTagKeywords = map[string]string{
+	"minLength":        "string",
+	"maxLength":        "string",
+	"minBytes":         "string",
+	"maxBytes":         "string",
+	"bytes":            "string",
+	"pattern":          "string",
+	"format":           "string",
+	"contentMediaType": "string",
+	"multipleOf":       "number",
+	"minimum":          "number",
+	"maximum":          "number",
+	"exclusiveMaximum": "number",
+	"exclusiveMinimum": "number",
+	"minItems":         "array",
+	"maxItems":         "array",
+	"uniqueItems":      "array",
+	"minProperties":    "object",
+	"maxProperties":    "object",
+}
+
+// checkTags records the StrictTags problems of the field's schema tag.
+func (r *Reflector) checkTags(definitions Definitions, parent reflect.Type, f reflect.StructField, property *Schema) {
+	tag := f.Tag.Get(r.schemaTagKey())
+	fail := func(err error) {
+		*r.tagErrors = append(*r.tagErrors, fmt.Errorf("%s.%s: %w", parent.Name(), f.Name, err))
+	}
+	for _, err := range ValidateTag(tag) {
+		fail(err)
+	}
+
+	typ := tagSchemaType(definitions, property)
+	display := typ
+	// enum and format on arrays apply to the items
+	itemTyp, itemDisplay := "", ""
+	if typ == "array" && property.Items != nil {
+		itemTyp = tagSchemaType(definitions, property.Items)
+		itemDisplay = itemTyp
+	}
+	if typ == "integer" {
+		typ = "number"
+	}
+	if itemTyp == "integer" {
+		itemTyp = "number"
+	}
+
+	seen := map[string]bool{}
+	for _, item := range splitOnUnescapedCommas(tag) {
+		name := strings.SplitN(item, "=", 2)[0]
+		seen[name] = true
+		want, ok := typedTagKeywords[name]
+		if !ok {
+			continue
+		}
+		if name == "format" && typ == "array" {
+			if itemTyp != "" && want != itemTyp {
+				fail(fmt.Errorf("keyword %q does not apply to items of %s", name, itemDisplay))
+			}
+			continue
+		}
+		if typ != "" && want != typ {
+			fail(fmt.Errorf("keyword %q does not apply to %s", name, display))
+		}
+	}
+	if seen["type"] && (seen["oneof_type"] || seen["anyof_type"]) || seen["oneof_type"] && seen["anyof_type"] {
+		fail(errors.New("conflicting keywords type, oneof_type and anyof_type"))
+	}
+	for _, name := range []string{"enum", "enum_titled", "not_const"} {
+		if !seen[name] {
+			continue
+		}
+		if name == "enum" && typ == "array" {
+			if itemTyp != "string" && itemTyp != "number" {
+				fail(fmt.Errorf("keyword %q requires items of string, integer or number", name))
+			}
+			continue
+		}
+		if typ != "string" && typ != "number" {
+			fail(fmt.Errorf("keyword %q requires a string, integer or number", name))
+		}
+	}
+}
+
+// tagSchemaType provides the type of the schema, or of the definition it
+// references.
+func tagSchemaType(definitions Definitions, s *Schema) string {
+	if def, ok := definitions[strings.TrimPrefix(s.Ref, "#/$defs/")]; ok && s.Ref != "" {
+		return def.Type
+	}
+	return s.Type
+}
@@ -0,0 +1,90 @@
+package jsonschema
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DebugTree renders the schema as an indented outline of property names,
+// types, required markers (`*`) and references. Definitions are listed after
+// the root. This is meant for quick inspection, not for parsing.
+func (t *Schema) DebugTree() string {
+	var b strings.Builder
+	t.writeTree(&b, "#", false, 0)
+
+	names := make([]string, 0, len(t.Definitions))
+	for name := range t.Definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t.Definitions[name].writeTree(&b, "$defs/"+name, false, 0)
+	}
+	return b.String()
+}
+
+func (t *Schema) writeTree(b *strings.Builder, name string, required bool, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(name)
+	if required {
+		b.WriteString("*")
+	}
+	b.WriteString(": ")
+	b.WriteString(t.treeLabel())
+	b.WriteString("\n")
+
+	if t.Properties != nil {
+		requiredSet := make(map[string]bool, len(t.Required))
+		for _, r := range t.Required {
+			requiredSet[r] = true
+		}
+		for _, key := range t.Properties.Keys() {
+			v, _ := t.Properties.Get(key)
+			if p, ok := v.(*Schema); ok {
+				p.writeTree(b, key, requiredSet[key], depth+1)
+			}
+		}
+	}
+
+	patterns := make([]string, 0, len(t.PatternProperties))
+	for pattern := range t.PatternProperties {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		t.PatternProperties[pattern].writeTree(b, "/"+pattern+"/", false, depth+1)
+	}
+
+	for i, s := range t.PrefixItems {
+		s.writeTree(b, "["+strconv.Itoa(i)+"]", false, depth+1)
+	}
+	if t.Items != nil {
+		t.Items.writeTree(b, "[]", false, depth+1)
+	}
+	for i, s := range t.AllOf {
+		s.writeTree(b, "allOf["+strconv.Itoa(i)+"]", false, depth+1)
+	}
+	for i, s := range t.AnyOf {
+		s.writeTree(b, "anyOf["+strconv.Itoa(i)+"]", false, depth+1)
+	}
+	for i, s := range t.OneOf {
+		s.writeTree(b, "oneOf["+strconv.Itoa(i)+"]", false, depth+1)
+	}
+}
+
+func (t *Schema) treeLabel() string {
+	switch {
+	case t.boolean != nil:
+		return strconv.FormatBool(*t.boolean)
+	case t.Ref != "":
+		return "$ref " + t.Ref
+	case len(t.Types) > 0:
+		return strings.Join(t.Types, "|")
+	case t.Type != "" && t.Format != "":
+		return t.Type + " (" + t.Format + ")"
+	case t.Type != "":
+		return t.Type
+	}
+	return "any"
+}
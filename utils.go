@@ -16,3 +16,10 @@ func ToSnakeCase(str string) string {
 	snake = matchAllCap.ReplaceAllString(snake, "${1}-${2}")
 	return strings.ToLower(snake)
 }
+
+// humanizeName splits a CamelCase type name into space separated words,
+// such as `UserProfile` into `User Profile`.
+func humanizeName(str string) string {
+	words := matchFirstCap.ReplaceAllString(str, "${1} ${2}")
+	return matchAllCap.ReplaceAllString(words, "${1} ${2}")
+}